@@ -15,14 +15,16 @@ package main
 import (
 	"fmt"
 	"time"
+
+	"go-patterns-examples/internal/clock"
 )
 
-func batcher(in <-chan int, max int, every time.Duration) <-chan []int {
+func batcher(clk clock.Clock, in <-chan int, max int, every time.Duration) <-chan []int {
 	out := make(chan []int)
 	go func() {
 		defer close(out)
 
-		ticker := time.NewTicker(every)
+		ticker := clk.NewTicker(every)
 		defer ticker.Stop()
 
 		var buf []int
@@ -47,7 +49,7 @@ func batcher(in <-chan int, max int, every time.Duration) <-chan []int {
 				if len(buf) >= max {
 					flush()
 				}
-			case <-ticker.C:
+			case <-ticker.C():
 				flush()
 			}
 		}
@@ -65,7 +67,7 @@ func main() {
 		}
 	}()
 
-	for b := range batcher(in, 4, 200*time.Millisecond) {
+	for b := range batcher(clock.Real{}, in, 4, 200*time.Millisecond) {
 		fmt.Println("batch:", b)
 	}
 }
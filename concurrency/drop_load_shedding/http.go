@@ -0,0 +1,48 @@
+// http.go
+//
+// The drop policy applied to net/http: a request that finds every slot busy
+// is shed with 503 and Retry-After instead of queueing behind the others.
+//
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type LoadShedder struct {
+	slots      chan struct{}
+	retryAfter time.Duration
+	dropped    atomic.Uint64
+}
+
+// NewLoadShedder admits up to maxInFlight concurrent requests and tells shed
+// clients to come back after retryAfter.
+func NewLoadShedder(maxInFlight int, retryAfter time.Duration) *LoadShedder {
+	return &LoadShedder{
+		slots:      make(chan struct{}, maxInFlight),
+		retryAfter: retryAfter,
+	}
+}
+
+func (s *LoadShedder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			s.dropped.Add(1)
+			secs := int64(math.Max(1, math.Ceil(s.retryAfter.Seconds())))
+			w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-s.slots }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *LoadShedder) Dropped() uint64 { return s.dropped.Load() }
@@ -7,11 +7,15 @@
 //
 //   - Non-blocking send using select { case ch <- v: default: }
 //   - Keeping the system responsive under load
+//   - The same policy as HTTP middleware: shed with 503 and Retry-After
 //
 package main
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"time"
 )
 
@@ -38,4 +42,33 @@ func main() {
 
 	close(events)
 	fmt.Println("dropped:", dropped)
+
+	httpDemo()
+}
+
+func httpDemo() {
+	shed := NewLoadShedder(2, time.Second)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, "ok")
+	})
+	srv := httptest.NewServer(shed.Middleware(slow))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				fmt.Println("get:", err)
+				return
+			}
+			resp.Body.Close()
+			fmt.Println("request", i, resp.Status, "retry-after=", resp.Header.Get("Retry-After"))
+		}(i)
+	}
+	wg.Wait()
+	fmt.Println("http dropped:", shed.Dropped())
 }
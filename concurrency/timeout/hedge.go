@@ -0,0 +1,165 @@
+// hedge.go
+//
+// Hedged requests: if the first call has not returned within a delay, fire a
+// backup and take whichever succeeds first, canceling the rest.
+//
+//   - At most maxHedges backups per call
+//   - The delay can track a latency percentile of recent successful calls
+//   - A failed call triggers the next hedge immediately
+//
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+type Hedger struct {
+	delay     time.Duration
+	maxHedges int
+	clock     clock.Clock
+
+	// adaptive delay: percentile of the last len(samples) latencies
+	mu         sync.Mutex
+	adaptive   bool
+	percentile float64
+	samples    []time.Duration
+	next       int
+	filled     bool
+
+	hedges atomic.Uint64
+}
+
+type Option func(*Hedger)
+
+// WithPercentile derives the hedge delay from the p-th percentile (0..1) of the
+// last window successful latencies, once that many have been seen. It panics
+// if p is outside 0..1 or window is not positive.
+func WithPercentile(p float64, window int) Option {
+	if p < 0 || p > 1 {
+		panic("hedge: percentile outside 0..1")
+	}
+	if window <= 0 {
+		panic("hedge: non-positive percentile window")
+	}
+	return func(h *Hedger) {
+		h.adaptive = true
+		h.percentile = p
+		h.samples = make([]time.Duration, window)
+	}
+}
+
+func WithClock(c clock.Clock) Option { return func(h *Hedger) { h.clock = c } }
+
+// NewHedger waits delay before each backup and sends at most maxHedges of them.
+func NewHedger(delay time.Duration, maxHedges int, opts ...Option) *Hedger {
+	h := &Hedger{delay: delay, maxHedges: maxHedges, clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Delay returns the wait before the next hedge.
+func (h *Hedger) Delay() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.adaptive || !h.filled {
+		return h.delay
+	}
+	sorted := append([]time.Duration(nil), h.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(h.percentile*float64(len(sorted)-1))]
+}
+
+// Hedges returns how many backup calls have been sent.
+func (h *Hedger) Hedges() uint64 { return h.hedges.Load() }
+
+func (h *Hedger) observe(d time.Duration) {
+	if len(h.samples) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[h.next] = d
+	h.next++
+	if h.next == len(h.samples) {
+		h.next = 0
+		h.filled = true
+	}
+}
+
+type hedgeResult[T any] struct {
+	val T
+	err error
+}
+
+// Hedge runs fn, adding backups per h until one succeeds. It returns the first
+// success, or the last error once every call has failed.
+func Hedge[T any](ctx context.Context, h *Hedger, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stops the calls that lost
+
+	results := make(chan hedgeResult[T], h.maxHedges+1)
+	launched, failed := 0, 0
+	launch := func() {
+		if launched > 0 {
+			h.hedges.Add(1)
+		}
+		launched++
+		go func() {
+			start := h.clock.Now()
+			v, err := fn(ctx)
+			if err == nil {
+				h.observe(h.clock.Since(start))
+			}
+			results <- hedgeResult[T]{v, err}
+		}()
+	}
+
+	launch()
+	var lastErr error
+	for {
+		// Once every hedge is out, only results or ctx can end the wait.
+		var t clock.Timer
+		var fire <-chan time.Time
+		if launched <= h.maxHedges {
+			t = h.clock.NewTimer(h.Delay())
+			fire = t.C()
+		}
+		stop := func() {
+			if t != nil {
+				t.Stop()
+			}
+		}
+
+		select {
+		case r := <-results:
+			stop()
+			if r.err == nil {
+				return r.val, nil
+			}
+			lastErr = r.err
+			failed++
+			if launched <= h.maxHedges {
+				launch()
+			} else if failed == launched {
+				var zero T
+				return zero, lastErr
+			}
+		case <-fire:
+			launch()
+		case <-ctx.Done():
+			stop()
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
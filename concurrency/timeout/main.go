@@ -7,12 +7,14 @@
 //
 //   - Deriving a timeout context
 //   - Select on ctx.Done() to stop waiting
+//   - Hedging: race a backup request against a slow one
 //
 package main
 
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"time"
 )
 
@@ -31,4 +33,35 @@ func main() {
 
 	res, err := slowOperation(ctx)
 	fmt.Println("res:", res, "err:", err)
+
+	hedgeDemo()
+}
+
+// flakyLatency is usually fast but sometimes stalls, like a replica in GC.
+func flakyLatency(ctx context.Context) (string, error) {
+	d := 20 * time.Millisecond
+	if rand.Intn(5) == 0 {
+		d = 400 * time.Millisecond
+	}
+	select {
+	case <-time.After(d):
+		return "completed", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func hedgeDemo() {
+	h := NewHedger(50*time.Millisecond, 2, WithPercentile(0.9, 10))
+
+	var worst time.Duration
+	for i := 0; i < 30; i++ {
+		start := time.Now()
+		if _, err := Hedge(context.Background(), h, flakyLatency); err != nil {
+			fmt.Println("hedge err:", err)
+		}
+		worst = max(worst, time.Since(start))
+	}
+	fmt.Println("hedged: worst latency", worst.Truncate(10*time.Millisecond),
+		"hedges sent", h.Hedges(), "current delay", h.Delay().Truncate(time.Millisecond))
 }
@@ -0,0 +1,308 @@
+// adaptive_concurrency.go
+//
+// This example demonstrates an adaptive concurrency limiter for outbound calls.
+// Instead of a fixed semaphore size, the allowed number of in-flight calls is
+// adjusted from the latency each call observes.
+//
+// Key ideas illustrated:
+//
+//   - Calls over the current limit are rejected immediately (no queueing)
+//   - AIMD: grow by one while busy, cut multiplicatively on drops or timeouts
+//   - Vegas: estimate queueing from RTT vs. the no-load RTT
+//   - Gradient: scale the limit by minRTT/RTT plus a small queue allowance
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+// Sample is what one completed call tells the algorithm.
+type Sample struct {
+	Start    time.Time
+	RTT      time.Duration
+	InFlight int  // calls in flight when this one started, including it
+	Dropped  bool // timed out or was rejected downstream
+}
+
+// Algorithm computes the next limit from the current one and a sample. It is
+// called under the limiter's lock, so implementations may keep state.
+type Algorithm interface {
+	Update(limit float64, s Sample) float64
+}
+
+// AIMD adds Increase when the limit is being used and multiplies by Backoff on
+// a drop or when RTT exceeds Timeout. Calls that started before the last cut
+// do not cut again, so one congestion episode shrinks the limit once.
+type AIMD struct {
+	Increase float64
+	Backoff  float64
+	Timeout  time.Duration
+
+	lastCut time.Time
+}
+
+func (a *AIMD) Update(limit float64, s Sample) float64 {
+	if s.Dropped || (a.Timeout > 0 && s.RTT > a.Timeout) {
+		if s.Start.Before(a.lastCut) {
+			return limit
+		}
+		a.lastCut = s.Start.Add(s.RTT)
+		return limit * a.Backoff
+	}
+	if float64(s.InFlight)*2 >= limit {
+		return limit + a.Increase
+	}
+	return limit
+}
+
+// Vegas compares RTT with the lowest RTT seen (the no-load RTT) to estimate how
+// many calls are queued downstream, growing while the queue is short and
+// shrinking once it is long.
+type Vegas struct {
+	minRTT time.Duration
+}
+
+func (v *Vegas) Update(limit float64, s Sample) float64 {
+	if v.minRTT == 0 || s.RTT < v.minRTT {
+		v.minRTT = s.RTT
+	}
+	if s.Dropped {
+		return limit - math.Max(1, math.Log10(limit))
+	}
+	if float64(s.InFlight)*2 < limit || s.RTT <= 0 {
+		return limit
+	}
+
+	queue := limit * (1 - float64(v.minRTT)/float64(s.RTT))
+	step := math.Max(1, math.Log10(limit))
+	switch {
+	case queue <= 3*step:
+		return limit + step
+	case queue >= 6*step:
+		return limit - step
+	default:
+		return limit
+	}
+}
+
+// Gradient moves the limit towards limit*minRTT/RTT plus sqrt(limit) of queue
+// allowance, smoothed so a single outlier does not swing it.
+type Gradient struct {
+	Tolerance float64 // RTT may exceed minRTT by this factor before shrinking
+	Smoothing float64 // weight of the new estimate, 0..1
+
+	minRTT time.Duration
+}
+
+func (g *Gradient) Update(limit float64, s Sample) float64 {
+	if g.minRTT == 0 || s.RTT < g.minRTT {
+		g.minRTT = s.RTT
+	}
+	if s.Dropped {
+		return limit / 2
+	}
+
+	gradient := 1.0 // an RTT too short to measure says there is no queue
+	if s.RTT > 0 {
+		gradient = math.Max(0.5, math.Min(1, g.Tolerance*float64(g.minRTT)/float64(s.RTT)))
+	}
+	target := limit*gradient + math.Sqrt(limit)
+	return limit*(1-g.Smoothing) + target*g.Smoothing
+}
+
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+type AdaptiveLimiter struct {
+	mu       sync.Mutex
+	alg      Algorithm
+	limit    float64
+	min, max int
+	inFlight int
+	clock    clock.Clock
+
+	rejected atomic.Uint64
+}
+
+type Option func(*AdaptiveLimiter)
+
+func WithClock(c clock.Clock) Option { return func(l *AdaptiveLimiter) { l.clock = c } }
+
+// NewAdaptiveLimiter keeps the limit within [min, max], starting at initial.
+// min is raised to 1, since a limit of 0 admits no calls and so never learns
+// to grow again; max is raised to min and initial is clamped into the range.
+func NewAdaptiveLimiter(alg Algorithm, initial, min, max int, opts ...Option) *AdaptiveLimiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	initial = int(math.Max(float64(min), math.Min(float64(max), float64(initial))))
+
+	l := &AdaptiveLimiter{
+		alg:   alg,
+		limit: float64(initial),
+		min:   min,
+		max:   max,
+		clock: clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Token is held for the duration of one call and must be released exactly
+// once with Success, Dropped or Ignore.
+type Token struct {
+	l        *AdaptiveLimiter
+	start    time.Time
+	inFlight int
+	done     atomic.Bool
+}
+
+// Acquire admits a call if fewer than Limit calls are in flight.
+func (l *AdaptiveLimiter) Acquire() (*Token, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		l.rejected.Add(1)
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	return &Token{l: l, start: l.clock.Now(), inFlight: l.inFlight}, nil
+}
+
+func (t *Token) Success() { t.release(true, false) }
+
+// Dropped reports a call that timed out or was rejected downstream.
+func (t *Token) Dropped() { t.release(true, true) }
+
+// Ignore releases the slot without feeding the algorithm, e.g. for errors that
+// say nothing about load.
+func (t *Token) Ignore() { t.release(false, false) }
+
+func (t *Token) release(sample, dropped bool) {
+	if !t.done.CompareAndSwap(false, true) {
+		return
+	}
+
+	l := t.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if !sample {
+		return
+	}
+	next := l.alg.Update(l.limit, Sample{
+		Start:    t.start,
+		RTT:      l.clock.Since(t.start),
+		InFlight: t.inFlight,
+		Dropped:  dropped,
+	})
+	if math.IsNaN(next) {
+		return // keep the current limit rather than poison it
+	}
+	l.limit = math.Max(float64(l.min), math.Min(float64(l.max), next))
+}
+
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+func (l *AdaptiveLimiter) Rejected() uint64 { return l.rejected.Load() }
+
+// Do runs fn under the limiter. Deadline errors count as drops; other errors
+// are ignored for limit purposes.
+func (l *AdaptiveLimiter) Do(ctx context.Context, fn func(context.Context) error) error {
+	t, err := l.Acquire()
+	if err != nil {
+		return err
+	}
+	defer t.Ignore() // releases the slot if fn panics; a no-op otherwise
+
+	err = fn(ctx)
+	switch {
+	case err == nil:
+		t.Success()
+	case errors.Is(err, context.DeadlineExceeded):
+		t.Dropped()
+	default:
+		t.Ignore()
+	}
+	return err
+}
+
+// downstream gets slower once more than capacity calls run at the same time.
+type downstream struct {
+	capacity int64
+	inFlight atomic.Int64
+}
+
+func (d *downstream) call(ctx context.Context) error {
+	n := d.inFlight.Add(1)
+	defer d.inFlight.Add(-1)
+
+	latency := 10 * time.Millisecond
+	if n > d.capacity {
+		latency = time.Duration(n) * latency / time.Duration(d.capacity)
+	}
+	select {
+	case <-time.After(latency):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func run(name string, l *AdaptiveLimiter) {
+	dep := &downstream{capacity: 8}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 40; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				callCtx, cancelCall := context.WithTimeout(ctx, 50*time.Millisecond)
+				if err := l.Do(callCtx, dep.call); errors.Is(err, ErrLimitExceeded) {
+					time.Sleep(2 * time.Millisecond)
+				}
+				cancelCall()
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Printf("%-8s limit=%d in-flight=%d rejected=%d\n", name, l.Limit(), l.InFlight(), l.Rejected())
+	}
+	cancel()
+	wg.Wait()
+}
+
+func main() {
+	run("aimd", NewAdaptiveLimiter(&AIMD{Increase: 1, Backoff: 0.9, Timeout: 25 * time.Millisecond}, 4, 1, 64))
+	run("vegas", NewAdaptiveLimiter(&Vegas{}, 4, 1, 64))
+	run("gradient", NewAdaptiveLimiter(&Gradient{Tolerance: 1.5, Smoothing: 0.2}, 4, 1, 64))
+}
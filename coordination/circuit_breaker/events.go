@@ -0,0 +1,117 @@
+// events.go
+//
+// Observability for the circuit breaker.
+//
+//   - Subscribers receive every state transition with its reason
+//   - Metrics returns call counters and the time spent in each state
+//
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "CLOSED"
+	case Open:
+		return "OPEN"
+	case HalfOpen:
+		return "HALF-OPEN"
+	case ForcedOpen:
+		return "FORCED-OPEN"
+	case ForcedClosed:
+		return "FORCED-CLOSED"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+type StateChange struct {
+	From   State
+	To     State
+	Reason string
+	At     time.Time
+}
+
+func (c StateChange) String() string {
+	return fmt.Sprintf("%s -> %s (%s)", c.From, c.To, c.Reason)
+}
+
+// Subscribe returns a channel of state transitions until ctx is canceled.
+// Delivery is best-effort: a subscriber whose buffer is full misses events.
+func (cb *CircuitBreaker) Subscribe(ctx context.Context, buf int) <-chan StateChange {
+	ch := make(chan StateChange, buf)
+
+	cb.mu.Lock()
+	cb.subs[ch] = struct{}{}
+	cb.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		cb.mu.Lock()
+		delete(cb.subs, ch)
+		close(ch)
+		cb.mu.Unlock()
+	}()
+
+	return ch
+}
+
+func (cb *CircuitBreaker) setState(to State, reason string) {
+	now := cb.clock.Now()
+	from := cb.state
+
+	cb.metrics.timeIn[from] += now.Sub(cb.metrics.stateSince)
+	cb.metrics.stateSince = now
+	cb.state = to
+	cb.generation++
+
+	ev := StateChange{From: from, To: to, Reason: reason, At: now}
+	for ch := range cb.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+type counters struct {
+	successes  uint64
+	failures   uint64
+	ignored    uint64
+	rejections uint64
+	timeIn     [ForcedClosed + 1]time.Duration
+	stateSince time.Time
+}
+
+type Metrics struct {
+	State      State
+	Successes  uint64
+	Failures   uint64
+	Ignored    uint64
+	Rejections uint64
+	TimeIn     map[State]time.Duration
+}
+
+func (cb *CircuitBreaker) Metrics() Metrics {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	m := Metrics{
+		State:      cb.state,
+		Successes:  cb.metrics.successes,
+		Failures:   cb.metrics.failures,
+		Ignored:    cb.metrics.ignored,
+		Rejections: cb.metrics.rejections,
+		TimeIn:     map[State]time.Duration{},
+	}
+	for s, d := range cb.metrics.timeIn {
+		m.TimeIn[State(s)] = d
+	}
+	m.TimeIn[cb.state] += cb.clock.Since(cb.metrics.stateSince)
+	return m
+}
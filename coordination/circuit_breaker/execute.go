@@ -0,0 +1,116 @@
+// execute.go
+//
+// Permits and Execute tie the outcome of a call to the admission that allowed
+// it, so callers cannot forget to report a result or report it twice.
+//
+//   - A Permit records its result exactly once
+//   - Results from permits issued before a state change are discarded
+//   - A Classifier decides which errors count against the dependency
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeFailure
+	OutcomeIgnore // neither success nor failure, e.g. canceled by the caller
+)
+
+type Classifier func(err error) Outcome
+
+// DefaultClassifier treats any error as a failure except context.Canceled,
+// which reflects the caller giving up rather than the dependency misbehaving.
+func DefaultClassifier(err error) Outcome {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, context.Canceled):
+		return OutcomeIgnore
+	default:
+		return OutcomeFailure
+	}
+}
+
+func WithClassifier(c Classifier) Option {
+	return func(cb *CircuitBreaker) { cb.classify = c }
+}
+
+type Permit struct {
+	cb    *CircuitBreaker
+	gen   uint64
+	start time.Time
+	done  atomic.Bool
+}
+
+// Acquire admits a call and returns a permit whose Done must be called with the
+// call's result.
+func (cb *CircuitBreaker) Acquire() (*Permit, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err := cb.admit(); err != nil {
+		return nil, err
+	}
+	return &Permit{cb: cb, gen: cb.generation, start: cb.clock.Now()}, nil
+}
+
+// Done records the result of the call. Only the first call has an effect.
+func (p *Permit) Done(err error) {
+	if !p.done.CompareAndSwap(false, true) {
+		return
+	}
+	p.cb.complete(p.gen, p.cb.classify(err), p.cb.clock.Since(p.start))
+}
+
+func (cb *CircuitBreaker) complete(gen uint64, o Outcome, elapsed time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if gen != cb.generation {
+		return
+	}
+	switch o {
+	case OutcomeIgnore:
+		cb.metrics.ignored++
+		if cb.state == HalfOpen {
+			cb.releaseProbe()
+		}
+	case OutcomeFailure:
+		cb.onFailure(elapsed)
+	default:
+		cb.onSuccess(elapsed)
+	}
+}
+
+// Execute runs fn if the breaker admits it and records the result. A panic in
+// fn is recorded as a failure and then re-raised.
+func Execute[T any](ctx context.Context, cb *CircuitBreaker, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	p, err := cb.Acquire()
+	if err != nil {
+		return zero, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			p.Done(fmt.Errorf("circuit breaker: call panicked: %v", r))
+			panic(r)
+		}
+	}()
+
+	v, err := fn(ctx)
+	p.Done(err)
+	return v, err
+}
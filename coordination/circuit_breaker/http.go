@@ -0,0 +1,112 @@
+// http.go
+//
+// net/http adapters for the circuit breaker.
+//
+//   - BreakerMiddleware answers 503 with Retry-After while the breaker rejects
+//   - 5xx responses from the wrapped handler count as failures
+//   - BreakerTransport does the same for outgoing client requests
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError carries an HTTP status so classifiers can tell server errors
+// from client errors.
+type StatusError struct{ Code int }
+
+func (e StatusError) Error() string { return fmt.Sprintf("status %d", e.Code) }
+
+// RetryAfter estimates how long until the breaker admits calls again.
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == Open {
+		if d := cb.openUntil.Sub(cb.clock.Now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+func BreakerMiddleware(cb *CircuitBreaker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := Execute(r.Context(), cb, func(context.Context) (struct{}, error) {
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status >= 500 {
+				return struct{}{}, StatusError{rec.status}
+			}
+			return struct{}{}, nil
+		})
+
+		if errors.Is(err, ErrOpen) || errors.Is(err, ErrTooManyProbes) {
+			w.Header().Set("Retry-After", seconds(cb.RetryAfter()))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// seconds renders d as whole seconds, rounded up and at least one.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Max(1, math.Ceil(d.Seconds()))), 10)
+}
+
+// BreakerTransport fails fast while the breaker is open and records 5xx
+// responses and transport errors as failures. 5xx responses are still
+// returned to the caller.
+type BreakerTransport struct {
+	Breaker *CircuitBreaker
+	Base    http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *BreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := Execute(req.Context(), t.Breaker, func(context.Context) (*http.Response, error) {
+		resp, err := base.RoundTrip(req)
+		if err == nil && resp.StatusCode >= 500 {
+			return resp, StatusError{resp.StatusCode}
+		}
+		return resp, err
+	})
+
+	var se StatusError
+	if errors.As(err, &se) {
+		return resp, nil
+	}
+	return resp, err
+}
@@ -5,16 +5,28 @@
 // Key ideas illustrated:
 //
 //   - CLOSED -> OPEN after failure threshold
-//   - OPEN -> HALF-OPEN after cool-down
-//   - HALF-OPEN -> CLOSED on success (or back to OPEN on failure)
+//   - CLOSED -> OPEN on failure or slow-call ratio over a sliding window (optional)
+//   - OPEN -> HALF-OPEN after cool-down (optionally growing on repeated trips)
+//   - HALF-OPEN admits a bounded number of concurrent probe calls, and trips
+//     again if probes never report back
+//   - HALF-OPEN -> CLOSED after N consecutive successes (or back to OPEN on failure)
+//   - Operators can pin the breaker open or closed until it is reset
+//   - HTTP middleware and a client transport put the breaker in front of net/http
 //
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go-patterns-examples/internal/backoff"
+	"go-patterns-examples/internal/clock"
 )
 
 type State int
@@ -23,6 +35,8 @@ const (
 	Closed State = iota
 	Open
 	HalfOpen
+	ForcedOpen   // pinned open by an operator until Reset
+	ForcedClosed // pinned closed by an operator until Reset
 )
 
 type CircuitBreaker struct {
@@ -34,62 +48,262 @@ type CircuitBreaker struct {
 	openUntil      time.Time
 	coolDown       time.Duration
 	halfOpenTrials int
+
+	// optional growth of the open duration on successive trips
+	coolDownBackoff *backoff.Exponential
+	trips           int // trips since the breaker last closed
+
+	// HALF-OPEN bookkeeping
+	probes         int // in-flight probe calls
+	probeSuccesses int
+	halfOpenSince  time.Time
+	maxProbeWait   time.Duration
+
+	// generation changes on every state transition so permits issued in an
+	// earlier state can be recognised as stale
+	generation uint64
+	classify   Classifier
+
+	subs    map[chan StateChange]struct{}
+	metrics counters
+
+	clock clock.Clock
+
+	// sliding-window mode; when window is set it replaces failureThresh
+	window       window
+	minCalls     int
+	failureRate  float64
+	slowCall     time.Duration
+	slowCallRate float64
+}
+
+type Option func(*CircuitBreaker)
+
+// WithCountWindow trips on ratios computed over the last size calls.
+func WithCountWindow(size int) Option {
+	return func(cb *CircuitBreaker) { cb.window = newCountWindow(size) }
+}
+
+// WithTimeWindow trips on ratios computed over calls in the last size,
+// aggregated into the given number of buckets.
+func WithTimeWindow(size time.Duration, buckets int) Option {
+	return func(cb *CircuitBreaker) { cb.window = newTimeWindow(size, buckets) }
+}
+
+func WithClock(c clock.Clock) Option {
+	return func(cb *CircuitBreaker) { cb.clock = c }
+}
+
+// WithCoolDownBackoff grows the open duration on each trip that follows a
+// failed HALF-OPEN probe. A zero b.Base starts from the breaker's coolDown.
+func WithCoolDownBackoff(b backoff.Exponential) Option {
+	return func(cb *CircuitBreaker) {
+		if b.Base == 0 {
+			b.Base = cb.coolDown
+		}
+		cb.coolDownBackoff = &b
+	}
 }
 
-func NewCircuitBreaker(thresh int, coolDown time.Duration, halfOpenTrials int) *CircuitBreaker {
-	return &CircuitBreaker{
+func WithFailureRate(ratio float64) Option {
+	return func(cb *CircuitBreaker) { cb.failureRate = ratio }
+}
+
+// WithSlowCalls counts calls taking at least threshold as slow and trips when
+// their share of the window reaches ratio.
+func WithSlowCalls(threshold time.Duration, ratio float64) Option {
+	return func(cb *CircuitBreaker) {
+		cb.slowCall = threshold
+		cb.slowCallRate = ratio
+	}
+}
+
+// WithMaxProbeWait trips the breaker again when HALF-OPEN has been full of
+// probes for longer than d without a result, e.g. because a caller never
+// reported one. Default one minute; 0 waits forever.
+func WithMaxProbeWait(d time.Duration) Option {
+	return func(cb *CircuitBreaker) { cb.maxProbeWait = d }
+}
+
+// WithMinimumCalls sets how many calls the window needs before ratios are evaluated.
+func WithMinimumCalls(n int) Option {
+	return func(cb *CircuitBreaker) { cb.minCalls = n }
+}
+
+func NewCircuitBreaker(thresh int, coolDown time.Duration, halfOpenTrials int, opts ...Option) *CircuitBreaker {
+	if halfOpenTrials < 1 {
+		halfOpenTrials = 1
+	}
+	cb := &CircuitBreaker{
 		state:          Closed,
 		failureThresh:  thresh,
 		coolDown:       coolDown,
 		halfOpenTrials: halfOpenTrials,
+		minCalls:       10,
+		failureRate:    0.5,
+		slowCallRate:   1,
+		maxProbeWait:   time.Minute,
+		classify:       DefaultClassifier,
+		subs:           map[chan StateChange]struct{}{},
+		clock:          clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(cb)
 	}
+	cb.metrics.stateSince = cb.clock.Now()
+	return cb
 }
 
-var ErrOpen = errors.New("circuit breaker is open")
+var (
+	ErrOpen          = errors.New("circuit breaker is open")
+	ErrTooManyProbes = errors.New("circuit breaker is half-open: probe limit reached")
+)
 
 func (cb *CircuitBreaker) Allow() error {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
+	return cb.admit()
+}
 
-	now := time.Now()
+func (cb *CircuitBreaker) admit() error {
+	now := cb.clock.Now()
 	switch cb.state {
 	case Open:
-		if now.After(cb.openUntil) {
-			cb.state = HalfOpen
-			cb.failures = 0
-			return nil
+		if !now.After(cb.openUntil) {
+			cb.metrics.rejections++
+			return ErrOpen
+		}
+		cb.setState(HalfOpen, "cool-down elapsed")
+		cb.failures = 0
+		cb.probes = 0
+		cb.probeSuccesses = 0
+		cb.halfOpenSince = now
+		fallthrough
+	case HalfOpen:
+		if cb.probes >= cb.halfOpenTrials {
+			cb.metrics.rejections++
+			if cb.maxProbeWait > 0 && now.Sub(cb.halfOpenSince) > cb.maxProbeWait {
+				// lost probes would otherwise hold HALF-OPEN forever
+				cb.trip(fmt.Sprintf("no probe result within %v", cb.maxProbeWait))
+				return ErrOpen
+			}
+			return ErrTooManyProbes
 		}
-		return ErrOpen
+		cb.probes++
+		return nil
+	case ForcedOpen:
+		cb.metrics.rejections++
+		return ErrForcedOpen
 	default:
 		return nil
 	}
 }
 
-func (cb *CircuitBreaker) OnSuccess() {
+func (cb *CircuitBreaker) OnSuccess() { cb.Record(false, 0) }
+
+func (cb *CircuitBreaker) OnFailure() { cb.Record(true, 0) }
+
+// Record reports the outcome of a call together with how long it took, so the
+// sliding window can track slow calls.
+func (cb *CircuitBreaker) Record(failed bool, elapsed time.Duration) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.failures = 0
-	cb.state = Closed
+	if failed {
+		cb.onFailure(elapsed)
+	} else {
+		cb.onSuccess(elapsed)
+	}
 }
 
-func (cb *CircuitBreaker) OnFailure() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
+func (cb *CircuitBreaker) onSuccess(elapsed time.Duration) {
+	cb.metrics.successes++
+	cb.failures = 0
+	switch cb.state {
+	case Open, ForcedOpen, ForcedClosed:
+		// late result of a call admitted before the trip, or an override in force
+		return
+	case HalfOpen:
+		cb.releaseProbe()
+		cb.probeSuccesses++
+		if cb.probeSuccesses >= cb.halfOpenTrials {
+			cb.setState(Closed, fmt.Sprintf("%d probe(s) succeeded", cb.probeSuccesses))
+			cb.trips = 0
+			cb.resetWindow()
+		}
+		return
+	}
+	cb.observe(false, elapsed)
+}
 
+func (cb *CircuitBreaker) onFailure(elapsed time.Duration) {
+	cb.metrics.failures++
 	cb.failures++
-	if cb.state == HalfOpen && cb.failures >= 1 {
-		cb.trip()
+	if cb.state == HalfOpen {
+		cb.releaseProbe()
+		cb.trip("probe failed")
+		return
+	}
+	if cb.state != Closed {
+		return
+	}
+	if cb.window != nil {
+		cb.observe(true, elapsed)
+		return
+	}
+	if cb.failures >= cb.failureThresh {
+		cb.trip(fmt.Sprintf("%d consecutive failures", cb.failures))
+	}
+}
+
+func (cb *CircuitBreaker) observe(failed bool, elapsed time.Duration) {
+	if cb.window == nil {
+		return
+	}
+
+	now := cb.clock.Now()
+	cb.window.record(now, sample{
+		failed: failed,
+		slow:   cb.slowCall > 0 && elapsed >= cb.slowCall,
+	})
+
+	s := cb.window.stats(now)
+	if s.calls < cb.minCalls {
 		return
 	}
-	if cb.state == Closed && cb.failures >= cb.failureThresh {
-		cb.trip()
+	switch {
+	case s.failureRate() >= cb.failureRate:
+		cb.trip(fmt.Sprintf("failure rate %.0f%% over %d calls", 100*s.failureRate(), s.calls))
+	case cb.slowCall > 0 && s.slowRate() >= cb.slowCallRate:
+		cb.trip(fmt.Sprintf("slow-call rate %.0f%% over %d calls", 100*s.slowRate(), s.calls))
+	}
+}
+
+func (cb *CircuitBreaker) releaseProbe() {
+	if cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+func (cb *CircuitBreaker) resetWindow() {
+	if cb.window != nil {
+		cb.window.reset()
 	}
 }
 
-func (cb *CircuitBreaker) trip() {
-	cb.state = Open
-	cb.openUntil = time.Now().Add(cb.coolDown)
+func (cb *CircuitBreaker) trip(reason string) {
+	cb.setState(Open, reason)
+	cb.openUntil = cb.clock.Now().Add(cb.nextCoolDown())
+	cb.resetWindow()
+}
+
+func (cb *CircuitBreaker) nextCoolDown() time.Duration {
+	d := cb.coolDown
+	if cb.coolDownBackoff != nil {
+		d = cb.coolDownBackoff.Delay(cb.trips)
+	}
+	cb.trips++
+	return d
 }
 
 func (cb *CircuitBreaker) State() State {
@@ -98,7 +312,7 @@ func (cb *CircuitBreaker) State() State {
 	return cb.state
 }
 
-func main() {
+func consecutiveDemo() {
 	cb := NewCircuitBreaker(3, 400*time.Millisecond, 1)
 
 	// dependency: fail first 5 calls, then succeed
@@ -130,3 +344,243 @@ func main() {
 		time.Sleep(120 * time.Millisecond)
 	}
 }
+
+func slidingWindowDemo() {
+	// 40% failures interleaved with successes never reach 3 in a row,
+	// but do cross a 30% failure rate over the last 10 calls.
+	cb := NewCircuitBreaker(3, 400*time.Millisecond, 1,
+		WithCountWindow(10),
+		WithMinimumCalls(5),
+		WithFailureRate(0.3),
+		WithSlowCalls(50*time.Millisecond, 0.5),
+	)
+
+	for i := 1; i <= 12; i++ {
+		if err := cb.Allow(); err != nil {
+			fmt.Println(i, "blocked:", err, "state=", cb.State())
+			continue
+		}
+		failed := i%5 == 2 || i%5 == 4
+		cb.Record(failed, 10*time.Millisecond)
+		fmt.Println(i, "failed=", failed, "state=", cb.State())
+	}
+}
+
+func halfOpenDemo() {
+	// a fake clock makes the cool-down deterministic
+	clk := clock.NewFake(time.Now())
+	cb := NewCircuitBreaker(1, 100*time.Millisecond, 2, WithClock(clk))
+	_ = cb.Allow()
+	cb.OnFailure() // trip
+	clk.Advance(150 * time.Millisecond)
+
+	// a burst of callers after cool-down: only 2 probes get through
+	admitted := 0
+	for i := 1; i <= 5; i++ {
+		if err := cb.Allow(); err != nil {
+			fmt.Println("caller", i, "rejected:", err)
+			continue
+		}
+		admitted++
+	}
+	for i := 0; i < admitted; i++ {
+		cb.OnSuccess()
+		fmt.Println("probe", i+1, "ok", "state=", cb.State())
+	}
+
+	// a probe whose result is never recorded does not hold HALF-OPEN forever
+	lost := NewCircuitBreaker(1, 100*time.Millisecond, 1, WithClock(clk), WithMaxProbeWait(time.Second))
+	_ = lost.Allow()
+	lost.OnFailure()
+	clk.Advance(150 * time.Millisecond)
+	_ = lost.Allow() // probe admitted, outcome lost
+	clk.Advance(2 * time.Second)
+	fmt.Println("lost probe:", lost.Allow(), "state=", lost.State())
+}
+
+func executeDemo() {
+	// 4xx responses are the caller's fault and say nothing about the dependency
+	classify := func(err error) Outcome {
+		var se StatusError
+		if errors.As(err, &se) && se.Code < 500 {
+			return OutcomeIgnore
+		}
+		return DefaultClassifier(err)
+	}
+	cb := NewCircuitBreaker(2, time.Second, 1, WithClassifier(classify))
+
+	codes := []int{404, 400, 503, 503, 200}
+	for i, code := range codes {
+		body, err := Execute(context.Background(), cb, func(ctx context.Context) (string, error) {
+			if code >= 400 {
+				return "", StatusError{code}
+			}
+			return "ok", nil
+		})
+		fmt.Println(i+1, "body=", body, "err=", err, "state=", cb.State())
+	}
+
+	// permits record exactly once, even on panic
+	cb2 := NewCircuitBreaker(1, time.Second, 1)
+	func() {
+		defer func() { fmt.Println("recovered:", recover(), "state=", cb2.State()) }()
+		_, _ = Execute(context.Background(), cb2, func(ctx context.Context) (int, error) {
+			panic("boom")
+		})
+	}()
+}
+
+func eventsDemo() {
+	cb := NewCircuitBreaker(2, 50*time.Millisecond, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := cb.Subscribe(ctx, 8)
+
+	cb.OnFailure()
+	cb.OnFailure()
+	_ = cb.Allow() // rejected
+	time.Sleep(60 * time.Millisecond)
+	_ = cb.Allow()
+	cb.OnSuccess()
+
+	cancel()
+	for ev := range events {
+		fmt.Println("event:", ev)
+	}
+
+	m := cb.Metrics()
+	fmt.Printf("metrics: state=%s ok=%d failed=%d rejected=%d open=%s\n",
+		m.State, m.Successes, m.Failures, m.Rejections, m.TimeIn[Open].Truncate(10*time.Millisecond))
+}
+
+func registryDemo() {
+	reg := NewRegistry(Settings{Threshold: 2, CoolDown: time.Second, HalfOpenTrials: 1}, 50*time.Millisecond)
+
+	for _, host := range []string{"api.a", "api.b", "api.c"} {
+		_ = reg.Get(host).Allow()
+	}
+	reg.Get("api.b").OnFailure()
+	reg.Get("api.b").OnFailure()
+
+	time.Sleep(60 * time.Millisecond)
+	_ = reg.Get("api.c").Allow()
+	fmt.Println("evicted:", reg.EvictIdle())
+
+	for _, st := range reg.List() {
+		fmt.Println(st.Name, st.State, "failures=", st.Metrics.Failures)
+	}
+}
+
+func overrideDemo() {
+	cb := NewCircuitBreaker(1, time.Millisecond, 1)
+
+	cb.ForceClosed()
+	cb.OnFailure() // does not trip while pinned closed
+	fmt.Println("after failure:", cb.State())
+
+	cb.ForceOpen()
+	fmt.Println("allow:", cb.Allow(), "state=", cb.State())
+
+	cb.Reset()
+	fmt.Println("after reset:", cb.State(), "allow:", cb.Allow())
+}
+
+func coolDownDemo() {
+	clk := clock.NewFake(time.Now())
+	cb := NewCircuitBreaker(1, 100*time.Millisecond, 1,
+		WithClock(clk),
+		WithCoolDownBackoff(backoff.Exponential{Max: time.Second, Jitter: 0.1}),
+	)
+
+	// every probe fails: each trip stays open longer, up to the cap
+	_ = cb.Allow()
+	cb.OnFailure()
+	for i := 1; i <= 5; i++ {
+		waited := time.Duration(0)
+		for cb.Allow() != nil {
+			clk.Advance(10 * time.Millisecond)
+			waited += 10 * time.Millisecond
+		}
+		fmt.Println("trip", i, "open for ~", waited)
+		cb.OnFailure()
+	}
+}
+
+func httpDemo() {
+	var failing atomic.Bool
+	failing.Store(true)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "db down", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+
+	cb := NewCircuitBreaker(2, time.Second, 1)
+	srv := httptest.NewServer(BreakerMiddleware(cb, h))
+	defer srv.Close()
+
+	for i := 1; i <= 3; i++ {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			fmt.Println("get:", err)
+			return
+		}
+		resp.Body.Close()
+		fmt.Println("server", i, resp.Status, "retry-after=", resp.Header.Get("Retry-After"))
+	}
+
+	// client side: stop calling an upstream that keeps returning 5xx
+	upstream := httptest.NewServer(h)
+	defer upstream.Close()
+	client := &http.Client{Transport: &BreakerTransport{Breaker: NewCircuitBreaker(2, 200*time.Millisecond, 1)}}
+	get := func(i int) {
+		resp, err := client.Get(upstream.URL)
+		if err != nil {
+			fmt.Println("client", i, "err:", err)
+			return
+		}
+		resp.Body.Close()
+		fmt.Println("client", i, resp.Status)
+	}
+	for i := 1; i <= 3; i++ {
+		get(i)
+	}
+
+	// the upstream recovers: after the cool-down a probe closes the breaker
+	failing.Store(false)
+	time.Sleep(250 * time.Millisecond)
+	for i := 4; i <= 5; i++ {
+		get(i)
+	}
+}
+
+func main() {
+	fmt.Println("== consecutive failures")
+	consecutiveDemo()
+
+	fmt.Println("== sliding window")
+	slidingWindowDemo()
+
+	fmt.Println("== half-open probes")
+	halfOpenDemo()
+
+	fmt.Println("== execute")
+	executeDemo()
+
+	fmt.Println("== events and metrics")
+	eventsDemo()
+
+	fmt.Println("== registry")
+	registryDemo()
+
+	fmt.Println("== overrides")
+	overrideDemo()
+
+	fmt.Println("== cool-down backoff")
+	coolDownDemo()
+
+	fmt.Println("== http")
+	httpDemo()
+}
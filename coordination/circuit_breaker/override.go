@@ -0,0 +1,45 @@
+// override.go
+//
+// Administrative overrides for incidents.
+//
+//   - ForceOpen rejects every call, e.g. to take a dependency out by hand
+//   - ForceClosed admits every call and ignores failures while investigating
+//   - Reset clears any override and starts over in CLOSED
+//
+package main
+
+import "fmt"
+
+// ErrForcedOpen matches ErrOpen with errors.Is.
+var ErrForcedOpen = fmt.Errorf("%w (forced)", ErrOpen)
+
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != ForcedOpen {
+		cb.setState(ForcedOpen, "forced open")
+	}
+}
+
+func (cb *CircuitBreaker) ForceClosed() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != ForcedClosed {
+		cb.setState(ForcedClosed, "forced closed")
+	}
+}
+
+// Reset clears any override and failure history and returns to CLOSED.
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.probes = 0
+	cb.probeSuccesses = 0
+	cb.trips = 0
+	cb.resetWindow()
+	cb.setState(Closed, "reset")
+}
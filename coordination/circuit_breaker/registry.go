@@ -0,0 +1,122 @@
+// registry.go
+//
+// A registry of named circuit breakers, e.g. one per downstream host.
+//
+//   - Breakers are created lazily from a shared template
+//   - Breakers idle for longer than a TTL are evicted (unless not CLOSED)
+//   - List reports every breaker and its state for admin endpoints
+//
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+// Settings is the template every breaker in a Registry is built from.
+type Settings struct {
+	Threshold      int
+	CoolDown       time.Duration
+	HalfOpenTrials int
+	Options        []Option
+	Clock          clock.Clock // shared by the registry and its breakers; defaults to the wall clock
+}
+
+type registryEntry struct {
+	cb       *CircuitBreaker
+	lastUsed time.Time
+}
+
+type Registry struct {
+	mu       sync.Mutex
+	settings Settings
+	idleTTL  time.Duration
+	entries  map[string]*registryEntry
+}
+
+func NewRegistry(settings Settings, idleTTL time.Duration) *Registry {
+	if settings.Clock == nil {
+		settings.Clock = clock.Real{}
+	}
+	return &Registry{
+		settings: settings,
+		idleTTL:  idleTTL,
+		entries:  map[string]*registryEntry{},
+	}
+}
+
+// Get returns the breaker for name, creating it on first use. Look breakers up
+// per call rather than caching them, so idle tracking stays accurate.
+func (r *Registry) Get(name string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[name]
+	if !ok {
+		s := r.settings
+		opts := append([]Option{WithClock(s.Clock)}, s.Options...)
+		e = &registryEntry{cb: NewCircuitBreaker(s.Threshold, s.CoolDown, s.HalfOpenTrials, opts...)}
+		r.entries[name] = e
+	}
+	e.lastUsed = r.settings.Clock.Now()
+	return e.cb
+}
+
+// EvictIdle drops breakers unused for longer than the idle TTL and returns how
+// many were removed. Breakers that are not CLOSED are kept so an evicted host
+// does not silently lose its protection.
+func (r *Registry) EvictIdle() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cutoff := r.settings.Clock.Now().Add(-r.idleTTL)
+	n := 0
+	for name, e := range r.entries {
+		if e.lastUsed.Before(cutoff) && e.cb.State() == Closed {
+			delete(r.entries, name)
+			n++
+		}
+	}
+	return n
+}
+
+// Run evicts idle breakers every interval until ctx is canceled.
+func (r *Registry) Run(ctx context.Context, every time.Duration) {
+	t := r.settings.Clock.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+			r.EvictIdle()
+		}
+	}
+}
+
+type BreakerStatus struct {
+	Name     string
+	State    State
+	LastUsed time.Time
+	Metrics  Metrics
+}
+
+// List returns the status of every breaker, sorted by name.
+func (r *Registry) List() []BreakerStatus {
+	r.mu.Lock()
+	out := make([]BreakerStatus, 0, len(r.entries))
+	for name, e := range r.entries {
+		out = append(out, BreakerStatus{Name: name, LastUsed: e.lastUsed, Metrics: e.cb.Metrics()})
+	}
+	r.mu.Unlock()
+
+	for i := range out {
+		out[i].State = out[i].Metrics.State
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
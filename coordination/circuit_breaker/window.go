@@ -0,0 +1,146 @@
+// window.go
+//
+// Sliding windows used by the circuit breaker to trip on failure and slow-call
+// ratios instead of a consecutive-failure count.
+//
+//   - countWindow keeps the outcomes of the last N calls in a ring buffer
+//   - timeWindow aggregates outcomes into fixed-width buckets covering a duration
+//
+package main
+
+import "time"
+
+type sample struct {
+	failed bool
+	slow   bool
+}
+
+type windowStats struct {
+	calls    int
+	failures int
+	slow     int
+}
+
+func (s windowStats) failureRate() float64 {
+	if s.calls == 0 {
+		return 0
+	}
+	return float64(s.failures) / float64(s.calls)
+}
+
+func (s windowStats) slowRate() float64 {
+	if s.calls == 0 {
+		return 0
+	}
+	return float64(s.slow) / float64(s.calls)
+}
+
+func (s *windowStats) add(o sample, delta int) {
+	s.calls += delta
+	if o.failed {
+		s.failures += delta
+	}
+	if o.slow {
+		s.slow += delta
+	}
+}
+
+type window interface {
+	record(now time.Time, o sample)
+	stats(now time.Time) windowStats
+	reset()
+}
+
+// countWindow remembers the last len(ring) outcomes.
+type countWindow struct {
+	ring   []sample
+	next   int
+	filled bool
+	totals windowStats
+}
+
+func newCountWindow(size int) *countWindow {
+	if size < 1 {
+		size = 1
+	}
+	return &countWindow{ring: make([]sample, size)}
+}
+
+func (w *countWindow) record(_ time.Time, o sample) {
+	if w.filled {
+		w.totals.add(w.ring[w.next], -1)
+	}
+	w.ring[w.next] = o
+	w.totals.add(o, 1)
+
+	w.next++
+	if w.next == len(w.ring) {
+		w.next = 0
+		w.filled = true
+	}
+}
+
+func (w *countWindow) stats(time.Time) windowStats { return w.totals }
+
+func (w *countWindow) reset() {
+	w.next = 0
+	w.filled = false
+	w.totals = windowStats{}
+}
+
+type bucket struct {
+	epoch int64
+	windowStats
+}
+
+// timeWindow splits size into len(buckets) slots; a slot is reused once its
+// epoch falls out of the window.
+type timeWindow struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+func newTimeWindow(size time.Duration, buckets int) *timeWindow {
+	if buckets < 1 {
+		buckets = 1
+	}
+	width := size / time.Duration(buckets)
+	if width <= 0 {
+		width = time.Millisecond
+	}
+	return &timeWindow{width: width, buckets: make([]bucket, buckets)}
+}
+
+func (w *timeWindow) epoch(now time.Time) int64 {
+	return now.UnixNano() / int64(w.width)
+}
+
+func (w *timeWindow) record(now time.Time, o sample) {
+	e := w.epoch(now)
+	b := &w.buckets[e%int64(len(w.buckets))]
+	if b.epoch != e {
+		*b = bucket{epoch: e}
+	}
+	b.add(o, 1)
+}
+
+func (w *timeWindow) stats(now time.Time) windowStats {
+	e := w.epoch(now)
+	oldest := e - int64(len(w.buckets)) + 1
+
+	var s windowStats
+	for _, b := range w.buckets {
+		if b.epoch >= oldest && b.epoch <= e {
+			s.calls += b.calls
+			s.failures += b.failures
+			s.slow += b.slow
+		}
+	}
+	return s
+}
+
+func (w *timeWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
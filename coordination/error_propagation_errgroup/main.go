@@ -6,7 +6,10 @@
 // Key ideas illustrated:
 //
 //   - First error cancels sibling goroutines (via derived context)
-//   - Wait returns that first error
+//   - Wait returns that first error, or all of them joined in collect mode
+//   - SetLimit/TryGo bound the number of active goroutines
+//   - Panics become errors carrying the stack trace
+//   - ResultGroup gathers typed results in order, optionally up to a quorum
 //
 package main
 
@@ -14,40 +17,132 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 )
 
+// Group runs goroutines and collects their errors. The zero value is ready to
+// use; WithContext additionally cancels a derived context on the first error.
 type Group struct {
 	wg     sync.WaitGroup
-	cancel context.CancelFunc
+	cancel context.CancelCauseFunc
+	sem    chan struct{}
 
-	errOnce sync.Once
-	err     error
+	collect bool
+
+	mu   sync.Mutex
+	err  error   // first error
+	errs []error // every error, in collect mode
+}
+
+// PanicError is the error a goroutine reports when it panics.
+type PanicError struct {
+	Value any
+	Stack []byte
 }
 
+func (e *PanicError) Error() string { return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack) }
+
 func WithContext(parent context.Context) (*Group, context.Context) {
-	ctx, cancel := context.WithCancel(parent)
+	ctx, cancel := context.WithCancelCause(parent)
 	return &Group{cancel: cancel}, ctx
 }
 
+// SetLimit bounds the number of active goroutines; a negative n removes the
+// bound. It must not be called while goroutines are running.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// SetCollectAll makes Wait return every error joined with errors.Join instead
+// of only the first, and stops the first error from canceling the context.
+func (g *Group) SetCollectAll(on bool) { g.collect = on }
+
+// Go runs fn in a new goroutine, blocking first if the limit is reached.
 func (g *Group) Go(fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(fn)
+}
+
+// TryGo runs fn only if the limit allows another goroutine right now.
+func (g *Group) TryGo(fn func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(fn)
+	return true
+}
+
+func (g *Group) start(fn func() error) {
 	g.wg.Add(1)
 	go func() {
-		defer g.wg.Done()
-		if err := fn(); err != nil {
-			g.errOnce.Do(func() {
-				g.err = err
-				g.cancel()
-			})
+		defer g.done()
+		if err := g.call(fn); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *Group) call(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
 		}
 	}()
+	return fn()
+}
+
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.collect {
+		g.errs = append(g.errs, err)
+		return
+	}
+	if g.err == nil {
+		g.err = err
+		if g.cancel != nil {
+			g.cancel(err)
+		}
+	}
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
 }
 
 func (g *Group) Wait() error {
 	g.wg.Wait()
-	g.cancel()
-	return g.err
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	err := g.err
+	if g.collect {
+		err = errors.Join(g.errs...)
+	}
+	if g.cancel != nil {
+		g.cancel(err)
+	}
+	return err
 }
 
 func main() {
@@ -86,6 +181,99 @@ func main() {
 	})
 
 	if err := g.Wait(); err != nil {
-		fmt.Println("group error:", err)
+		fmt.Println("group error:", err, "cause:", context.Cause(ctx))
+	}
+
+	limitDemo()
+	collectDemo()
+	gatherDemo()
+	quorumDemo()
+}
+
+// replica answers after delay, or fails after -delay if delay is negative.
+func replica(ctx context.Context, id int, delay time.Duration) (string, error) {
+	down := delay < 0
+	if down {
+		delay = -delay
+	}
+	select {
+	case <-time.After(delay):
+		if down {
+			return "", fmt.Errorf("replica %d down", id)
+		}
+		return fmt.Sprintf("r%d", id), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func gatherDemo() {
+	rg, ctx := NewResultGroup[string](context.Background())
+	delays := []time.Duration{30, 10, -40, 50}
+	for i, d := range delays {
+		i, d := i, d
+		rg.Go(func() (string, error) { return replica(ctx, i+1, d*time.Millisecond) })
+	}
+
+	vals, err := rg.Wait()
+	fmt.Printf("gather: %q err: %v\n", vals, err)
+	for i, res := range rg.Results() {
+		fmt.Println("  call", i+1, "value:", res.Value, "err:", res.Err)
+	}
+}
+
+func quorumDemo() {
+	rg, ctx := NewResultGroup[string](context.Background())
+	rg.SetQuorum(2)
+	delays := []time.Duration{80, -1, 20, 40, 200}
+	for i, d := range delays {
+		i, d := i, d
+		rg.Go(func() (string, error) { return replica(ctx, i+1, d*time.Millisecond) })
+	}
+
+	vals, err := rg.Wait()
+	fmt.Printf("quorum: %q err: %v cause: %v\n", vals, err, context.Cause(ctx))
+}
+
+func limitDemo() {
+	var g Group // zero value, no context
+	g.SetLimit(2)
+
+	for i := 1; i <= 4; i++ {
+		i := i
+		ok := g.TryGo(func() error {
+			time.Sleep(50 * time.Millisecond)
+			fmt.Println("limited task", i, "done")
+			return nil
+		})
+		if !ok {
+			fmt.Println("limited task", i, "skipped: limit reached")
+		}
+	}
+	fmt.Println("limited wait:", g.Wait())
+}
+
+func collectDemo() {
+	var g Group
+	g.SetCollectAll(true)
+
+	g.Go(func() error { return errors.New("shard 1 unreachable") })
+	g.Go(func() error { return nil })
+	g.Go(func() error { return errors.New("shard 3 timed out") })
+	g.Go(func() error {
+		var ids []int
+		_ = ids[3] // panics: index out of range
+		return nil
+	})
+
+	err := g.Wait()
+	var pe *PanicError
+	fmt.Println("collected panic:", errors.As(err, &pe))
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		if errors.As(e, &pe) {
+			fmt.Println("  -", pe.Value)
+			continue
+		}
+		fmt.Println("  -", e)
 	}
 }
@@ -0,0 +1,118 @@
+// results.go
+//
+// ResultGroup is a Group for scatter/gather: each goroutine returns a value,
+// and Wait returns the values in the order the goroutines were submitted.
+//
+//   - Partial results stay available through Results after an error
+//   - With a quorum of K, the group stops once K calls have succeeded
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrQuorumReached = errors.New("errgroup: quorum reached")
+	ErrQuorumNotMet  = errors.New("errgroup: quorum not met")
+)
+
+type Result[T any] struct {
+	Value T
+	Err   error
+	Done  bool // false until the call has returned
+}
+
+type ResultGroup[T any] struct {
+	g *Group
+
+	mu        sync.Mutex
+	results   []Result[T]
+	quorum    int
+	successes int
+}
+
+// NewResultGroup returns a group whose context is canceled on the first error,
+// or, with a quorum, once enough calls have succeeded.
+func NewResultGroup[T any](parent context.Context) (*ResultGroup[T], context.Context) {
+	g, ctx := WithContext(parent)
+	return &ResultGroup[T]{g: g}, ctx
+}
+
+func (r *ResultGroup[T]) SetLimit(n int) { r.g.SetLimit(n) }
+
+// SetQuorum makes the group succeed once k calls have succeeded; errors no
+// longer cancel the others, and errors after the quorum is met are ignored.
+func (r *ResultGroup[T]) SetQuorum(k int) {
+	r.quorum = k
+	r.g.SetCollectAll(true)
+}
+
+func (r *ResultGroup[T]) Go(fn func() (T, error)) {
+	r.mu.Lock()
+	i := len(r.results)
+	r.results = append(r.results, Result[T]{})
+	r.mu.Unlock()
+
+	r.g.Go(func() error {
+		var v T
+		err := r.g.call(func() (err error) {
+			v, err = fn()
+			return err
+		})
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.results[i] = Result[T]{Value: v, Err: err, Done: true}
+		if r.quorum == 0 {
+			return err
+		}
+		if err == nil {
+			r.successes++
+			if r.successes == r.quorum {
+				r.g.cancel(ErrQuorumReached)
+			}
+		}
+		if r.successes >= r.quorum {
+			return nil
+		}
+		return err
+	})
+}
+
+// Wait returns every value in submission order (the zero value where a call
+// failed or did not finish) along with the group's error.
+func (r *ResultGroup[T]) Wait() ([]T, error) {
+	err := r.g.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.quorum > 0 {
+		if r.successes >= r.quorum {
+			err = nil
+		} else if err != nil {
+			err = fmt.Errorf("%w: %d of %d succeeded: %w", ErrQuorumNotMet, r.successes, r.quorum, err)
+		} else {
+			// fewer than k calls were submitted, and all of them succeeded
+			err = fmt.Errorf("%w: %d of %d succeeded", ErrQuorumNotMet, r.successes, r.quorum)
+		}
+	}
+
+	values := make([]T, len(r.results))
+	for i, res := range r.results {
+		values[i] = res.Value
+	}
+	return values, err
+}
+
+// Results returns the outcome of every call in submission order.
+func (r *ResultGroup[T]) Results() []Result[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Result[T](nil), r.results...)
+}
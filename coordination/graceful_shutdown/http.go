@@ -0,0 +1,238 @@
+// http.go
+//
+// Draining an HTTP server through the Shutdown coordinator.
+//
+//   - InFlight tracks requests in progress and can cancel the stragglers
+//   - Server fails readiness first, so load balancers stop routing to it
+//   - Then it stops accepting, lets in-flight requests finish within a grace
+//     period, and cancels whatever is still running
+//   - Metrics count completed requests and requests cut off
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCutOff is the cause of a request context canceled by CancelAll.
+var ErrCutOff = errors.New("request cut off by shutdown")
+
+type InFlightMetrics struct {
+	Active    int
+	Completed int64
+	CutOff    int64
+}
+
+type InFlight struct {
+	stop   context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	active int
+	idle   chan struct{} // closed when active drops to zero, if anyone waits
+
+	completed atomic.Int64
+	cutOff    atomic.Int64
+}
+
+func NewInFlight() *InFlight {
+	t := &InFlight{}
+	t.stop, t.cancel = context.WithCancel(context.Background())
+	return t
+}
+
+// Middleware counts each request while it runs and cancels its context on
+// CancelAll.
+func (t *InFlight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+		unhook := context.AfterFunc(t.stop, func() { cancel(ErrCutOff) })
+		defer unhook()
+
+		t.enter()
+		defer t.leave()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		if context.Cause(ctx) == ErrCutOff {
+			t.cutOff.Add(1)
+		} else {
+			t.completed.Add(1)
+		}
+	})
+}
+
+func (t *InFlight) enter() {
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+}
+
+func (t *InFlight) leave() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if t.active == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// Wait blocks until no request is in flight or ctx is done.
+func (t *InFlight) Wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CancelAll cancels the context of every request in flight, and of any that
+// arrive later, and returns how many were running.
+func (t *InFlight) CancelAll() int {
+	t.mu.Lock()
+	n := t.active
+	t.mu.Unlock()
+	t.cancel()
+	return n
+}
+
+func (t *InFlight) Metrics() InFlightMetrics {
+	t.mu.Lock()
+	active := t.active
+	t.mu.Unlock()
+	return InFlightMetrics{Active: active, Completed: t.completed.Load(), CutOff: t.cutOff.Load()}
+}
+
+type ServerOption func(*Server)
+
+// WithReadinessDelay is how long readiness fails before the server stops
+// accepting, giving load balancers time to notice. Default 5s.
+func WithReadinessDelay(d time.Duration) ServerOption {
+	return func(s *Server) { s.readinessDelay = d }
+}
+
+// WithDrainGrace is how long in-flight requests may run once the server stops
+// accepting. Default 10s.
+func WithDrainGrace(d time.Duration) ServerOption { return func(s *Server) { s.grace = d } }
+
+// WithStragglerTimeout is how long canceled requests get to return before
+// their connections are closed. Default 1s.
+func WithStragglerTimeout(d time.Duration) ServerOption {
+	return func(s *Server) { s.stragglers = d }
+}
+
+type Server struct {
+	srv      *http.Server
+	inflight *InFlight
+	ready    atomic.Bool
+
+	readinessDelay time.Duration
+	grace          time.Duration
+	stragglers     time.Duration
+}
+
+func NewServer(addr string, h http.Handler, opts ...ServerOption) *Server {
+	s := &Server{
+		inflight:       NewInFlight(),
+		readinessDelay: 5 * time.Second,
+		grace:          10 * time.Second,
+		stragglers:     time.Second,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	s.srv = &http.Server{Addr: addr, Handler: s.inflight.Middleware(h)}
+	return s
+}
+
+// Serve marks the server ready and serves l until shutdown. It returns nil
+// once the server has been shut down.
+func (s *Server) Serve(l net.Listener) error {
+	s.ready.Store(true)
+	if err := s.srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ReadyHandler answers 200 while the server takes traffic and 503 once it has
+// started shutting down.
+func (s *Server) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+}
+
+func (s *Server) Metrics() InFlightMetrics { return s.inflight.Metrics() }
+
+// Register adds the server's hooks to sd under name: failing readiness in the
+// stop-accepting phase, and draining in the drain phase.
+func (s *Server) Register(sd *Shutdown, name string) {
+	sd.Register(name+"-readiness", StopAccepting, s.unready,
+		WithPriority(10), WithTimeout(s.readinessDelay+time.Second))
+	sd.Register(name, Drain, s.drain, WithTimeout(s.grace+s.stragglers+time.Second))
+}
+
+func (s *Server) unready(ctx context.Context) error {
+	s.ready.Store(false)
+	select {
+	case <-time.After(s.readinessDelay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) drain(ctx context.Context) error {
+	graceCtx, cancel := context.WithTimeout(ctx, s.grace)
+	defer cancel()
+
+	// Shutdown closes the listeners and idle connections, then waits for
+	// active ones; it returns early only if the grace period runs out.
+	if err := s.srv.Shutdown(graceCtx); err == nil {
+		return nil
+	}
+
+	n := s.inflight.CancelAll()
+	waitCtx, cancel := context.WithTimeout(ctx, s.stragglers)
+	defer cancel()
+	err := s.inflight.Wait(waitCtx)
+	s.srv.Close()
+	if err != nil {
+		return fmt.Errorf("%d requests cut off, some did not return: %w", n, err)
+	}
+	return fmt.Errorf("%d requests cut off", n)
+}
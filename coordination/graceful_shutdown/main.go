@@ -7,16 +7,20 @@
 //   - os.Signal notification
 //   - context cancellation to stop background goroutines
 //   - WaitGroup to wait for in-flight work before exiting
+//   - a Shutdown coordinator running hooks in ordered phases with timeouts
+//   - draining an HTTP server: readiness flip, grace period, cut-off stragglers
 //
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
-	"os/signal"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -24,8 +28,14 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	sd := NewShutdown(
+		WithHardDeadline(2*time.Second),
+		WithDefaultTimeout(500*time.Millisecond),
+		WithForceExit(func(sig os.Signal) {
+			fmt.Println("received second", sig, "- forcing exit")
+			os.Exit(1)
+		}),
+	)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -45,10 +55,110 @@ func main() {
 		}
 	}()
 
-	fmt.Println("Press Ctrl+C to shutdown...")
-	<-sigCh
-	fmt.Println("signal received, shutting down...")
-	cancel()
+	sd.Register("intake", StopAccepting, func(context.Context) error {
+		fmt.Println("intake: no longer accepting work")
+		return nil
+	})
+	sd.Register("worker", Drain, func(ctx context.Context) error {
+		cancel()
+		done := make(chan struct{})
+		go func() { wg.Wait(); close(done) }()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	sd.Register("metrics", Flush, func(context.Context) error {
+		fmt.Println("metrics: flushed")
+		return nil
+	}, WithPriority(10))
+	sd.Register("log", Flush, func(context.Context) error {
+		return errors.New("log sink unreachable")
+	})
+	sd.Register("db", Close, func(context.Context) error {
+		// Ignores ctx, so the coordinator gives up on it after its timeout.
+		time.Sleep(time.Second)
+		return nil
+	}, WithTimeout(300*time.Millisecond))
+
+	// Stop on its own after a while so the example also runs unattended.
+	demo, stop := context.WithTimeoutCause(context.Background(), 1500*time.Millisecond, errors.New("demo timeout"))
+	defer stop()
+
+	fmt.Println("Press Ctrl+C to shutdown (twice to force)...")
+	report := sd.Wait(demo)
+	fmt.Println(report)
+	fmt.Println("timed out:", report.TimedOut())
+	if err := report.Err(); err != nil {
+		fmt.Println("shutdown errors:", err)
+	} else {
+		fmt.Println("clean exit")
+	}
+
+	httpDemo()
+}
+
+func httpDemo() {
+	fmt.Println("--- http drain ---")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/work", func(w http.ResponseWriter, r *http.Request) {
+		d, _ := time.ParseDuration(r.URL.Query().Get("d"))
+		select {
+		case <-time.After(d):
+			fmt.Fprintln(w, "done after", d)
+		case <-r.Context().Done():
+			http.Error(w, context.Cause(r.Context()).Error(), http.StatusServiceUnavailable)
+		}
+	})
+
+	srv := NewServer("127.0.0.1:0", mux,
+		WithReadinessDelay(100*time.Millisecond),
+		WithDrainGrace(300*time.Millisecond),
+		WithStragglerTimeout(200*time.Millisecond))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("listen:", err)
+		return
+	}
+	go srv.Serve(l)
+
+	probe := func() int {
+		rec := httptest.NewRecorder()
+		srv.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+
+	base := "http://" + l.Addr().String()
+	var wg sync.WaitGroup
+	for _, d := range []string{"50ms", "250ms", "2s"} {
+		wg.Add(1)
+		go func(d string) {
+			defer wg.Done()
+			resp, err := http.Get(base + "/work?d=" + d)
+			if err != nil {
+				fmt.Println("client", d, "error:", err)
+				return
+			}
+			resp.Body.Close()
+			fmt.Println("client", d, "status:", resp.StatusCode)
+		}(d)
+	}
+	time.Sleep(20 * time.Millisecond)
+	fmt.Println("readiness before:", probe())
+
+	sd := NewShutdown(WithHardDeadline(2 * time.Second))
+	srv.Register(sd, "http")
+	done := make(chan *Report)
+	go func() { done <- sd.Shutdown(context.Background()) }()
+
+	time.Sleep(20 * time.Millisecond)
+	fmt.Println("readiness during shutdown:", probe())
+
+	report := <-done
 	wg.Wait()
-	fmt.Println("clean exit")
+	fmt.Println(report)
+	fmt.Printf("metrics: %+v\n", srv.Metrics())
 }
@@ -0,0 +1,329 @@
+// shutdown.go
+//
+// A reusable shutdown coordinator.
+//
+//   - Components register hooks in phases: stop accepting, drain, flush, close
+//   - Phases run in order; within a phase, higher priority hooks run first and
+//     hooks of equal priority run concurrently
+//   - Every hook has its own timeout, and a global hard deadline bounds the lot
+//   - A second signal while shutting down forces the process to exit
+//   - The report lists which hooks failed, timed out, or never ran
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type Phase int
+
+const (
+	StopAccepting Phase = iota
+	Drain
+	Flush
+	Close
+)
+
+func (p Phase) String() string {
+	switch p {
+	case StopAccepting:
+		return "stop-accepting"
+	case Drain:
+		return "drain"
+	case Flush:
+		return "flush"
+	case Close:
+		return "close"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// Hook releases one component's resources. It should return when ctx is done;
+// a hook that does not is abandoned and reported as timed out.
+type Hook func(ctx context.Context) error
+
+type hook struct {
+	name     string
+	phase    Phase
+	priority int
+	timeout  time.Duration
+	fn       Hook
+}
+
+type HookOption func(*hook)
+
+// WithPriority orders hooks within a phase; higher runs first. Default 0.
+func WithPriority(p int) HookOption { return func(h *hook) { h.priority = p } }
+
+// WithTimeout overrides the coordinator's default hook timeout.
+func WithTimeout(d time.Duration) HookOption { return func(h *hook) { h.timeout = d } }
+
+type Option func(*Shutdown)
+
+// WithHardDeadline bounds the whole shutdown. Default 30s.
+func WithHardDeadline(d time.Duration) Option { return func(s *Shutdown) { s.deadline = d } }
+
+// WithDefaultTimeout is the timeout of hooks registered without one. Default 5s.
+func WithDefaultTimeout(d time.Duration) Option { return func(s *Shutdown) { s.timeout = d } }
+
+// WithSignals replaces the signals Wait listens for. Default SIGINT, SIGTERM.
+func WithSignals(sigs ...os.Signal) Option { return func(s *Shutdown) { s.signals = sigs } }
+
+// WithForceExit replaces what happens on a second signal; the default exits
+// with code 1.
+func WithForceExit(exit func(sig os.Signal)) Option { return func(s *Shutdown) { s.exit = exit } }
+
+type Shutdown struct {
+	deadline time.Duration
+	timeout  time.Duration
+	signals  []os.Signal
+	exit     func(sig os.Signal)
+
+	mu    sync.Mutex
+	hooks []hook
+
+	once     sync.Once
+	stopping chan struct{}
+	report   *Report
+}
+
+func NewShutdown(opts ...Option) *Shutdown {
+	s := &Shutdown{
+		deadline: 30 * time.Second,
+		timeout:  5 * time.Second,
+		signals:  []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		exit:     func(os.Signal) { os.Exit(1) },
+		stopping: make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Register adds a hook to run in phase. Hooks registered once shutdown has
+// started are not run.
+func (s *Shutdown) Register(name string, phase Phase, fn Hook, opts ...HookOption) {
+	h := hook{name: name, phase: phase, timeout: s.timeout, fn: fn}
+	for _, o := range opts {
+		o(&h)
+	}
+	s.mu.Lock()
+	s.hooks = append(s.hooks, h)
+	s.mu.Unlock()
+}
+
+// Stopping is closed when shutdown begins.
+func (s *Shutdown) Stopping() <-chan struct{} { return s.stopping }
+
+// Wait blocks until a signal arrives or ctx is done, then shuts down; the
+// report records which. A second signal during shutdown calls the force-exit
+// function.
+func (s *Shutdown) Wait(ctx context.Context) *Report {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, s.signals...)
+	defer signal.Stop(sigCh)
+
+	var sig os.Signal
+	var cause error
+	select {
+	case sig = <-sigCh:
+	case <-ctx.Done():
+		cause = context.Cause(ctx)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			s.exit(sig)
+		case <-done:
+		}
+	}()
+
+	return s.shutdown(context.Background(), sig, cause)
+}
+
+// Shutdown runs every hook phase by phase and reports the outcome. Only the
+// first call runs the hooks; later calls return the same report.
+func (s *Shutdown) Shutdown(ctx context.Context) *Report {
+	return s.shutdown(ctx, nil, nil)
+}
+
+func (s *Shutdown) shutdown(ctx context.Context, sig os.Signal, cause error) *Report {
+	s.once.Do(func() {
+		close(s.stopping)
+		s.report = s.run(ctx)
+		s.report.Signal, s.report.Cause = sig, cause
+	})
+	return s.report
+}
+
+func (s *Shutdown) run(ctx context.Context) *Report {
+	s.mu.Lock()
+	hooks := append([]hook(nil), s.hooks...)
+	s.mu.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if hooks[i].phase != hooks[j].phase {
+			return hooks[i].phase < hooks[j].phase
+		}
+		return hooks[i].priority > hooks[j].priority
+	})
+
+	ctx, cancel := context.WithTimeoutCause(ctx, s.deadline, ErrHardDeadline)
+	defer cancel()
+
+	start := time.Now()
+	r := &Report{}
+	for i := 0; i < len(hooks); {
+		// Hooks sharing a phase and priority form one concurrent step.
+		j := i + 1
+		for j < len(hooks) && hooks[j].phase == hooks[i].phase && hooks[j].priority == hooks[i].priority {
+			j++
+		}
+		if ctx.Err() != nil {
+			for _, h := range hooks[i:] {
+				r.Skipped = append(r.Skipped, h.name)
+			}
+			break
+		}
+		r.Hooks = append(r.Hooks, runStep(ctx, hooks[i:j])...)
+		i = j
+	}
+	if ctx.Err() != nil {
+		// the hard deadline, or the caller's ctx ending first
+		r.Aborted = context.Cause(ctx)
+		r.DeadlineExceeded = errors.Is(r.Aborted, ErrHardDeadline)
+	}
+	r.Elapsed = time.Since(start)
+	return r
+}
+
+func runStep(ctx context.Context, step []hook) []HookResult {
+	results := make([]HookResult, len(step))
+	var wg sync.WaitGroup
+	for i, h := range step {
+		wg.Add(1)
+		go func(i int, h hook) {
+			defer wg.Done()
+			results[i] = runHook(ctx, h)
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
+
+func runHook(ctx context.Context, h hook) HookResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	res := HookResult{Name: h.name, Phase: h.phase}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- fmt.Errorf("panic: %v", v)
+			}
+		}()
+		done <- h.fn(ctx)
+	}()
+
+	select {
+	case res.Err = <-done:
+	case <-ctx.Done():
+		// The hook may still be running; it is abandoned, not waited for.
+		res.Err = ctx.Err()
+		res.TimedOut = true
+	}
+	res.Elapsed = time.Since(start)
+	return res
+}
+
+type HookResult struct {
+	Name     string
+	Phase    Phase
+	Elapsed  time.Duration
+	Err      error
+	TimedOut bool
+}
+
+func (h HookResult) name() string { return h.Phase.String() + "/" + h.Name }
+
+// ErrHardDeadline is Report.Aborted when the coordinator's hard deadline passed.
+var ErrHardDeadline = errors.New("shutdown: hard deadline exceeded")
+
+type Report struct {
+	Signal           os.Signal // that started the shutdown, if any
+	Cause            error     // of Wait's ctx, if it ended first
+	Hooks            []HookResult
+	Skipped          []string // not run because the shutdown was cut short
+	Aborted          error    // why it was cut short, if it was
+	DeadlineExceeded bool     // Aborted is ErrHardDeadline
+	Elapsed          time.Duration
+}
+
+// TimedOut returns the names of hooks that did not finish within their timeout.
+func (r *Report) TimedOut() []string {
+	var names []string
+	for _, h := range r.Hooks {
+		if h.TimedOut {
+			names = append(names, h.name())
+		}
+	}
+	return names
+}
+
+// Err joins every hook error, or returns nil if the shutdown was clean.
+func (r *Report) Err() error {
+	var errs []error
+	for _, h := range r.Hooks {
+		if h.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name(), h.Err))
+		}
+	}
+	if len(r.Skipped) > 0 {
+		errs = append(errs, fmt.Errorf("skipped (%v): %s", r.Aborted, strings.Join(r.Skipped, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "shutdown took %v", r.Elapsed.Round(time.Millisecond))
+	switch {
+	case r.Signal != nil:
+		fmt.Fprintf(&b, " after %v", r.Signal)
+	case r.Cause != nil:
+		fmt.Fprintf(&b, " after %v", r.Cause)
+	}
+	if r.Aborted != nil {
+		fmt.Fprintf(&b, " (cut short: %v)", r.Aborted)
+	}
+	for _, h := range r.Hooks {
+		status := "ok"
+		switch {
+		case h.TimedOut:
+			status = "timed out"
+		case h.Err != nil:
+			status = "error: " + h.Err.Error()
+		}
+		fmt.Fprintf(&b, "\n  %-30s %-8v %s", h.name(), h.Elapsed.Round(time.Millisecond), status)
+	}
+	for _, name := range r.Skipped {
+		fmt.Fprintf(&b, "\n  %-30s skipped", name)
+	}
+	return b.String()
+}
@@ -0,0 +1,228 @@
+// algorithms.go
+//
+// Alternative rate-limiting algorithms behind a common Limiter interface, so an
+// endpoint can swap algorithms without changing its call sites.
+//
+//   - SlidingWindowLog: exact, remembers every admitted timestamp in the window
+//   - SlidingWindowCounter: approximate, weights the previous fixed window
+//   - GCRA: token bucket equivalent that stores a single timestamp
+//
+// Unlike a token bucket with a large burst, the sliding windows do not allow a
+// double burst across a window edge.
+//
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+type Limiter interface {
+	Allow() bool
+	Wait(ctx context.Context) error
+}
+
+var (
+	_ Limiter = (*RateLimiter)(nil)
+	_ Limiter = (*SlidingWindowLog)(nil)
+	_ Limiter = (*SlidingWindowCounter)(nil)
+	_ Limiter = (*GCRA)(nil)
+)
+
+// waitFor retries try until it admits the call or ctx is done; try reports how
+// long to wait before the next attempt.
+func waitFor(ctx context.Context, clk clock.Clock, try func(now time.Time) (bool, time.Duration)) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok, wait := try(clk.Now())
+		if ok {
+			return nil
+		}
+
+		t := clk.NewTimer(wait)
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// SlidingWindowLog admits at most limit events in any window-long interval.
+type SlidingWindowLog struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	log    []time.Time // admitted events, oldest first
+	clock  clock.Clock
+}
+
+func NewSlidingWindowLog(limit int, window time.Duration, opts ...Option) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		limit:  limit,
+		window: window,
+		log:    make([]time.Time, 0, limit),
+		clock:  newOptions(opts).clock,
+	}
+}
+
+func (l *SlidingWindowLog) Allow() bool {
+	ok, _ := l.try(l.clock.Now())
+	return ok
+}
+
+func (l *SlidingWindowLog) Wait(ctx context.Context) error { return waitFor(ctx, l.clock, l.try) }
+
+func (l *SlidingWindowLog) try(now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(l.log) && !l.log[i].After(cutoff) {
+		i++
+	}
+	l.log = append(l.log[:0], l.log[i:]...)
+
+	if len(l.log) < l.limit {
+		l.log = append(l.log, now)
+		return true, 0
+	}
+	if l.limit <= 0 {
+		return false, l.window
+	}
+	return false, l.log[0].Sub(cutoff)
+}
+
+// SlidingWindowCounter estimates the events in the trailing window from the
+// current fixed window's count plus the previous one's, weighted by how much
+// of it still overlaps.
+type SlidingWindowCounter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	curr, prev  int
+	clock       clock.Clock
+}
+
+func NewSlidingWindowCounter(limit int, window time.Duration, opts ...Option) *SlidingWindowCounter {
+	o := newOptions(opts)
+	return &SlidingWindowCounter{
+		limit:       limit,
+		window:      window,
+		windowStart: o.clock.Now().Truncate(window),
+		clock:       o.clock,
+	}
+}
+
+func (l *SlidingWindowCounter) Allow() bool {
+	ok, _ := l.try(l.clock.Now())
+	return ok
+}
+
+func (l *SlidingWindowCounter) Wait(ctx context.Context) error { return waitFor(ctx, l.clock, l.try) }
+
+func (l *SlidingWindowCounter) try(now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elapsed := now.Sub(l.windowStart); elapsed >= l.window {
+		if elapsed >= 2*l.window {
+			l.prev = 0
+		} else {
+			l.prev = l.curr
+		}
+		l.curr = 0
+		l.windowStart = now.Truncate(l.window)
+	}
+
+	untilNext := l.windowStart.Add(l.window).Sub(now)
+	overlap := float64(untilNext) / float64(l.window)
+	if float64(l.prev)*overlap+float64(l.curr)+1 <= float64(l.limit) {
+		l.curr++
+		return true, 0
+	}
+	if l.curr+1 > l.limit || l.prev == 0 {
+		return false, untilNext
+	}
+
+	// solve prev*overlap' + curr + 1 <= limit for the remaining overlap
+	need := 1 - float64(l.limit-l.curr-1)/float64(l.prev)
+	wait := time.Duration(need*float64(l.window)) - (l.window - untilNext)
+	if wait <= 0 {
+		wait = time.Millisecond
+	}
+	return false, wait
+}
+
+// GCRA (generic cell rate algorithm) tracks the theoretical arrival time of
+// the next event; an event is admitted if it is no earlier than that time
+// minus the burst tolerance.
+type GCRA struct {
+	mu        sync.Mutex
+	interval  time.Duration // emission interval, 1/rate
+	tolerance time.Duration // how far ahead of schedule a burst may run
+	tat       time.Time
+	clock     clock.Clock
+
+	// with a zero rate there is no schedule: burst events, then none
+	zero      bool
+	remaining int
+}
+
+func NewGCRA(r Limit, burst int, opts ...Option) *GCRA {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &GCRA{clock: newOptions(opts).clock}
+	if r <= 0 {
+		l.zero = true
+		l.remaining = burst
+		return l
+	}
+
+	l.interval = r.durationFromTokens(1)
+	if n := time.Duration(burst - 1); n > 0 && l.interval > InfDuration/n {
+		l.tolerance = InfDuration
+	} else {
+		l.tolerance = l.interval * n
+	}
+	return l
+}
+
+func (l *GCRA) Allow() bool {
+	ok, _ := l.try(l.clock.Now())
+	return ok
+}
+
+func (l *GCRA) Wait(ctx context.Context) error { return waitFor(ctx, l.clock, l.try) }
+
+func (l *GCRA) try(now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.zero {
+		if l.remaining == 0 {
+			return false, InfDuration
+		}
+		l.remaining--
+		return true, 0
+	}
+
+	tat := l.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	if allowAt := tat.Add(-l.tolerance); now.Before(allowAt) {
+		return false, allowAt.Sub(now)
+	}
+	l.tat = tat.Add(l.interval)
+	return true, 0
+}
@@ -0,0 +1,159 @@
+// distributed.go
+//
+// A distributed limiter keeps its token bucket in a Store shared by every
+// replica, so the replicas enforce one quota between them.
+//
+//   - Store performs the refill-and-take step atomically on its side, using
+//     its own clock so replicas with skewed clocks share one timeline
+//   - MemoryStore is the in-process implementation (and what RESP stand-in serves)
+//   - When the store is unreachable, calls fall back to a local Limiter, and
+//     skip the store for a cool-down instead of paying its timeout every call
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+// Store holds token buckets by key. Take refills the bucket for key at rate r
+// up to burst as of the store's own clock, then takes n tokens if that many
+// are available; otherwise it reports how long until they would be.
+type Store interface {
+	Take(ctx context.Context, key string, r Limit, burst, n int) (ok bool, wait time.Duration, err error)
+}
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+type MemoryStore struct {
+	clock   clock.Clock
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+}
+
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	return &MemoryStore{clock: newOptions(opts).clock, buckets: map[string]*bucketState{}}
+}
+
+func (m *MemoryStore) Take(_ context.Context, key string, r Limit, burst, n int) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucketState{tokens: float64(burst), last: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = refill(b.tokens, b.last, now, r, burst)
+	if now.After(b.last) {
+		b.last = now
+	}
+
+	if n > burst {
+		return false, InfDuration, nil
+	}
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		return true, 0, nil
+	}
+	return false, r.durationFromTokens(float64(n) - b.tokens), nil
+}
+
+type DistributedLimiter struct {
+	store    Store
+	key      string
+	limit    Limit
+	burst    int
+	fallback Limiter
+	timeout  time.Duration
+	coolDown time.Duration
+	clock    clock.Clock
+
+	downUntil atomic.Int64 // unix nanos; the store is skipped until then
+	fallbacks atomic.Uint64
+}
+
+var errStoreDown = errors.New("distributed limiter: store marked down")
+
+// NewDistributedLimiter shares rate r and burst for key through store. The
+// fallback limiter, typically sized to this replica's share of the quota, is
+// used whenever the store returns an error, and for a second afterwards.
+func NewDistributedLimiter(store Store, key string, r Limit, burst int, fallback Limiter, opts ...Option) *DistributedLimiter {
+	return &DistributedLimiter{
+		store:    store,
+		key:      key,
+		limit:    r,
+		burst:    burst,
+		fallback: fallback,
+		timeout:  50 * time.Millisecond,
+		coolDown: time.Second,
+		clock:    newOptions(opts).clock,
+	}
+}
+
+var _ Limiter = (*DistributedLimiter)(nil)
+
+func (d *DistributedLimiter) take(ctx context.Context) (bool, time.Duration, error) {
+	now := d.clock.Now().UnixNano()
+	if now < d.downUntil.Load() {
+		return false, 0, errStoreDown
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	ok, wait, err := d.store.Take(ctx, d.key, d.limit, d.burst, 1)
+	if err != nil && ctx.Err() != context.Canceled {
+		d.downUntil.Store(now + int64(d.coolDown))
+	}
+	return ok, wait, err
+}
+
+func (d *DistributedLimiter) Allow() bool {
+	ok, _, err := d.take(context.Background())
+	if err != nil {
+		d.fallbacks.Add(1)
+		return d.fallback.Allow()
+	}
+	return ok
+}
+
+func (d *DistributedLimiter) Wait(ctx context.Context) error {
+	for {
+		ok, wait, err := d.take(ctx)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			d.fallbacks.Add(1)
+			return d.fallback.Wait(ctx)
+		}
+		if ok {
+			return nil
+		}
+		if wait == InfDuration {
+			return ErrExceedsBurst
+		}
+
+		t := d.clock.NewTimer(wait)
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Fallbacks returns how many calls were decided locally because the store
+// was unavailable.
+func (d *DistributedLimiter) Fallbacks() uint64 { return d.fallbacks.Load() }
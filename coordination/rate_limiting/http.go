@@ -0,0 +1,97 @@
+// http.go
+//
+// net/http adapters for the limiters.
+//
+//   - RateLimitMiddleware answers 429 with Retry-After once the bucket is empty
+//   - Every response carries RateLimit-Limit/-Remaining/-Reset headers
+//   - RateLimitedTransport paces outgoing client requests
+//
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// take consumes one token if available and reports the state the RateLimit-*
+// headers describe.
+func (rl *RateLimiter) take() (ok bool, remaining int, retryAfter, reset time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.limit == Inf {
+		return true, rl.burst, 0, 0
+	}
+
+	now := rl.clock.Now()
+	tokens := rl.advance(now)
+	if tokens >= 1 {
+		tokens--
+		ok = true
+	} else {
+		retryAfter = rl.limit.durationFromTokens(1 - tokens)
+	}
+	rl.tokens = tokens
+	rl.last = now
+
+	return ok, int(math.Max(0, math.Floor(tokens))), retryAfter, rl.limit.durationFromTokens(float64(rl.burst) - tokens)
+}
+
+func RateLimitMiddleware(rl *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveLimited(rl, w, r, next)
+	})
+}
+
+// KeyedRateLimitMiddleware limits each client separately, e.g. by API key or
+// remote IP as returned by key.
+func KeyedRateLimitMiddleware(k *KeyedLimiter, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveLimited(k.Get(key(r)), w, r, next)
+	})
+}
+
+func serveLimited(rl *RateLimiter, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	ok, remaining, retryAfter, reset := rl.take()
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(rl.Burst()))
+	h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	setSeconds(h, "RateLimit-Reset", reset)
+
+	if !ok {
+		setSeconds(h, "Retry-After", retryAfter)
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	next.ServeHTTP(w, r)
+}
+
+// setSeconds sets a Retry-After style header to d in whole seconds, rounded
+// up. It leaves the header out when d is infinite, e.g. for Limit(0), since no
+// wait would help.
+func setSeconds(h http.Header, name string, d time.Duration) {
+	if d >= InfDuration {
+		return
+	}
+	h.Set(name, strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10))
+}
+
+// RateLimitedTransport waits for the limiter before sending each request.
+type RateLimitedTransport struct {
+	Limiter Limiter
+	Base    http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
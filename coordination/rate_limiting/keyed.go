@@ -0,0 +1,182 @@
+// keyed.go
+//
+// A keyed rate limiter keeps one token bucket per key (API key, client IP, ...).
+//
+//   - Keys are spread across shards, each with its own lock and map, as in
+//     performance/sharding
+//   - Buckets idle for longer than a TTL are evicted
+//   - The number of tracked keys is capped per shard, so the total never
+//     exceeds the limit; a full shard evicts its least recently seen key,
+//     which then starts over with a full bucket
+//
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+type keyedEntry struct {
+	rl       *RateLimiter
+	lastSeen time.Time
+}
+
+type keyedShard struct {
+	mu sync.Mutex
+	m  map[string]*keyedEntry
+}
+
+type KeyedLimiter struct {
+	shards   []keyedShard
+	limit    Limit
+	burst    int
+	idleTTL  time.Duration
+	maxKeys  int
+	perShard int
+	clock    clock.Clock
+}
+
+type KeyedOption func(*KeyedLimiter)
+
+// WithShards sets the number of shards, at least 1. Default 16.
+func WithShards(n int) KeyedOption {
+	return func(k *KeyedLimiter) { k.shards = make([]keyedShard, max(n, 1)) }
+}
+
+func WithIdleTTL(d time.Duration) KeyedOption { return func(k *KeyedLimiter) { k.idleTTL = d } }
+
+// WithMaxKeys bounds memory by tracking at most n keys. Each shard holds
+// n/shards of them, so a busy shard may evict before n keys are tracked in
+// total; with fewer keys than shards, the shard count is reduced to n.
+func WithMaxKeys(n int) KeyedOption { return func(k *KeyedLimiter) { k.maxKeys = n } }
+
+func WithKeyedClock(c clock.Clock) KeyedOption { return func(k *KeyedLimiter) { k.clock = c } }
+
+func NewKeyedLimiter(r Limit, burst int, opts ...KeyedOption) *KeyedLimiter {
+	k := &KeyedLimiter{
+		shards:  make([]keyedShard, 16),
+		limit:   r,
+		burst:   burst,
+		idleTTL: 10 * time.Minute,
+		clock:   clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	if k.maxKeys > 0 {
+		if k.maxKeys < len(k.shards) {
+			k.shards = k.shards[:k.maxKeys]
+		}
+		k.perShard = k.maxKeys / len(k.shards)
+	}
+	for i := range k.shards {
+		k.shards[i].m = map[string]*keyedEntry{}
+	}
+	return k
+}
+
+func (k *KeyedLimiter) idx(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(k.shards)))
+}
+
+// Get returns the bucket for key, creating it if needed.
+func (k *KeyedLimiter) Get(key string) *RateLimiter {
+	sh := &k.shards[k.idx(key)]
+	now := k.clock.Now()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	e, ok := sh.m[key]
+	if !ok {
+		if k.perShard > 0 && len(sh.m) >= k.perShard {
+			k.makeRoom(sh, now)
+		}
+		e = &keyedEntry{rl: NewRateLimiter(k.limit, k.burst, WithClock(k.clock))}
+		sh.m[key] = e
+	}
+	e.lastSeen = now
+	return e.rl
+}
+
+func (k *KeyedLimiter) Allow(key string) bool { return k.Get(key).Allow() }
+
+func (k *KeyedLimiter) AllowN(key string, n int) bool { return k.Get(key).AllowN(n) }
+
+func (k *KeyedLimiter) Wait(ctx context.Context, key string) error { return k.Get(key).Wait(ctx) }
+
+// makeRoom drops idle entries from a full shard, or its least recently seen
+// entry if none are idle. Callers hold sh.mu.
+func (k *KeyedLimiter) makeRoom(sh *keyedShard, now time.Time) {
+	if k.evictShard(sh, now) > 0 {
+		return
+	}
+
+	var oldestKey string
+	var oldest time.Time
+	found := false // "" is a valid key, so it cannot mark "none yet"
+	for key, e := range sh.m {
+		if !found || e.lastSeen.Before(oldest) {
+			oldestKey, oldest, found = key, e.lastSeen, true
+		}
+	}
+	delete(sh.m, oldestKey)
+}
+
+func (k *KeyedLimiter) evictShard(sh *keyedShard, now time.Time) int {
+	cutoff := now.Add(-k.idleTTL)
+	n := 0
+	for key, e := range sh.m {
+		if e.lastSeen.Before(cutoff) {
+			delete(sh.m, key)
+			n++
+		}
+	}
+	return n
+}
+
+// EvictIdle drops buckets unused for longer than the idle TTL and returns how
+// many were removed.
+func (k *KeyedLimiter) EvictIdle() int {
+	now := k.clock.Now()
+	n := 0
+	for i := range k.shards {
+		sh := &k.shards[i]
+		sh.mu.Lock()
+		n += k.evictShard(sh, now)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// Run evicts idle buckets every interval until ctx is canceled.
+func (k *KeyedLimiter) Run(ctx context.Context, every time.Duration) {
+	t := k.clock.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+			k.EvictIdle()
+		}
+	}
+}
+
+// Len returns the number of tracked keys.
+func (k *KeyedLimiter) Len() int {
+	n := 0
+	for i := range k.shards {
+		sh := &k.shards[i]
+		sh.mu.Lock()
+		n += len(sh.m)
+		sh.mu.Unlock()
+	}
+	return n
+}
@@ -1,74 +1,445 @@
 // rate_limiting.go
 //
-// This example demonstrates a simple token-bucket rate limiter using time.Ticker.
+// This example demonstrates a token-bucket rate limiter that refills lazily from
+// elapsed time instead of using a ticker goroutine.
 //
 // Key ideas illustrated:
 //
-//   - A ticker refills tokens at a fixed rate
-//   - Callers must acquire a token before proceeding
+//   - Tokens are recomputed from the time since the last call
+//   - Callers must acquire a token before proceeding (Allow, Wait or Reserve)
+//   - Fractional and very high rates work because the rate is a float
+//   - Limit and burst can be changed at runtime
+//   - A keyed limiter keeps one bucket per client with idle eviction
+//   - Sliding-window and GCRA limiters share a Limiter interface with the bucket
+//   - Replicas can share one quota through a store, falling back to local limits
+//   - HTTP middleware and a client transport apply the limiter to net/http
 //
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+// Limit is a rate in events per second.
+type Limit float64
+
+// Inf allows every event regardless of burst.
+const Inf = Limit(math.MaxFloat64)
+
+// InfDuration is returned as the delay of a reservation that can never be met.
+const InfDuration = time.Duration(math.MaxInt64)
+
+// Every converts a minimum interval between events into a Limit.
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
+	}
+	return 1 / Limit(interval.Seconds())
+}
+
+func (l Limit) durationFromTokens(tokens float64) time.Duration {
+	if l <= 0 {
+		return InfDuration
+	}
+	seconds := tokens / float64(l)
+	if seconds >= float64(InfDuration)/1e9 {
+		return InfDuration
+	}
+	return time.Duration(seconds * 1e9)
+}
+
+func (l Limit) tokensFromDuration(d time.Duration) float64 {
+	if l <= 0 {
+		return 0
+	}
+	return d.Seconds() * float64(l)
+}
+
+var (
+	ErrExceedsBurst        = errors.New("rate: request exceeds burst")
+	ErrWouldExceedDeadline = errors.New("rate: wait would exceed context deadline")
 )
 
 type RateLimiter struct {
-	tokens chan struct{}
-	stop   chan struct{}
+	mu     sync.Mutex
+	limit  Limit
+	burst  int
+	tokens float64
+	last   time.Time // last time tokens was updated
+	clock  clock.Clock
 }
 
-func NewRateLimiter(rps int, burst int) *RateLimiter {
+// Option configures any of the limiters in this example.
+type Option func(*options)
+
+type options struct {
+	clock clock.Clock
+}
+
+func WithClock(c clock.Clock) Option { return func(o *options) { o.clock = c } }
+
+func newOptions(opts []Option) options {
+	o := options{clock: clock.Real{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// NewRateLimiter allows events at rate r with bursts of up to burst events.
+// The bucket starts full.
+func NewRateLimiter(r Limit, burst int, opts ...Option) *RateLimiter {
 	rl := &RateLimiter{
-		tokens: make(chan struct{}, burst),
-		stop:   make(chan struct{}),
-	}
-
-	// fill burst initially
-	for i := 0; i < burst; i++ {
-		rl.tokens <- struct{}{}
-	}
-
-	interval := time.Second / time.Duration(rps)
-	t := time.NewTicker(interval)
-	go func() {
-		defer t.Stop()
-		for {
-			select {
-			case <-rl.stop:
-				return
-			case <-t.C:
-				select {
-				case rl.tokens <- struct{}{}:
-				default:
-				}
-			}
-		}
-	}()
+		limit:  r,
+		burst:  burst,
+		tokens: float64(burst),
+		clock:  newOptions(opts).clock,
+	}
+	rl.last = rl.clock.Now()
 	return rl
 }
 
-func (rl *RateLimiter) Stop() { close(rl.stop) }
+func (rl *RateLimiter) Limit() Limit {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limit
+}
+
+func (rl *RateLimiter) Burst() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.burst
+}
+
+// Tokens returns the number of tokens available now; negative while
+// reservations are outstanding.
+func (rl *RateLimiter) Tokens() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.advance(rl.clock.Now())
+}
+
+func (rl *RateLimiter) SetLimit(r Limit) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	rl.tokens = rl.advance(now)
+	rl.last = now
+	rl.limit = r
+}
+
+func (rl *RateLimiter) SetBurst(burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	rl.tokens = rl.advance(now)
+	rl.last = now
+	rl.burst = burst
+	if rl.tokens > float64(burst) {
+		rl.tokens = float64(burst)
+	}
+}
+
+func (rl *RateLimiter) Allow() bool { return rl.AllowN(1) }
+
+// AllowN reports whether n events may happen now, consuming tokens if so.
+func (rl *RateLimiter) AllowN(n int) bool {
+	return rl.reserveN(rl.clock.Now(), n, 0).ok
+}
+
+// Reservation holds tokens taken ahead of time; the caller must wait Delay
+// before acting, or Cancel to give the tokens back.
+type Reservation struct {
+	rl        *RateLimiter
+	ok        bool
+	tokens    int
+	timeToAct time.Time
+}
+
+// OK reports whether the limiter can ever satisfy the reservation.
+func (r *Reservation) OK() bool { return r.ok }
+
+// Delay is how long to wait before acting; InfDuration if !OK.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return InfDuration
+	}
+	d := r.timeToAct.Sub(r.rl.clock.Now())
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Cancel returns the reserved tokens if the reservation has not been acted on
+// yet. Reservations made after this one keep their delays.
+func (r *Reservation) Cancel() {
+	if !r.ok || r.tokens == 0 {
+		return
+	}
+
+	rl := r.rl
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.clock.Now()
+	if !r.timeToAct.After(now) {
+		return
+	}
+	rl.tokens = rl.advance(now) + float64(r.tokens)
+	if rl.tokens > float64(rl.burst) {
+		rl.tokens = float64(rl.burst)
+	}
+	rl.last = now
+	r.tokens = 0
+}
+
+func (rl *RateLimiter) Reserve() *Reservation { return rl.ReserveN(1) }
+
+func (rl *RateLimiter) ReserveN(n int) *Reservation {
+	return rl.reserveN(rl.clock.Now(), n, InfDuration)
+}
+
+func (rl *RateLimiter) Wait(ctx context.Context) error { return rl.WaitN(ctx, 1) }
+
+// WaitN blocks until n events are allowed or ctx is done. It fails fast if
+// the wait would outlast ctx's deadline.
+func (rl *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := rl.clock.Now()
+	maxWait := InfDuration
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = deadline.Sub(now)
+	}
 
-func (rl *RateLimiter) Acquire(ctx context.Context) error {
+	r := rl.reserveN(now, n, maxWait)
+	if !r.ok {
+		if n > rl.Burst() && rl.Limit() != Inf {
+			return ErrExceedsBurst
+		}
+		return ErrWouldExceedDeadline
+	}
+
+	delay := r.timeToAct.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	t := rl.clock.NewTimer(delay)
+	defer t.Stop()
 	select {
+	case <-t.C():
+		return nil
 	case <-ctx.Done():
+		r.Cancel()
 		return ctx.Err()
-	case <-rl.tokens:
-		return nil
 	}
 }
 
+// advance returns the tokens available at now without mutating the limiter.
+func (rl *RateLimiter) advance(now time.Time) float64 {
+	return refill(rl.tokens, rl.last, now, rl.limit, rl.burst)
+}
+
+// refill adds the tokens earned between last and now, capped at burst.
+func refill(tokens float64, last, now time.Time, r Limit, burst int) float64 {
+	if now.Before(last) {
+		last = now
+	}
+	tokens += r.tokensFromDuration(now.Sub(last))
+	if b := float64(burst); tokens > b {
+		tokens = b
+	}
+	return tokens
+}
+
+func (rl *RateLimiter) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.limit == Inf {
+		return &Reservation{rl: rl, ok: true, tokens: n, timeToAct: now}
+	}
+
+	tokens := rl.advance(now) - float64(n)
+	var wait time.Duration
+	if tokens < 0 {
+		wait = rl.limit.durationFromTokens(-tokens)
+	}
+
+	// A wait that can never end (e.g. Limit(0)) is refused outright, so it
+	// leaves no debt behind for a later SetLimit to pay off.
+	if n > rl.burst || wait == InfDuration || wait > maxWait {
+		return &Reservation{rl: rl}
+	}
+
+	rl.tokens = tokens
+	rl.last = now
+	return &Reservation{rl: rl, ok: true, tokens: n, timeToAct: now.Add(wait)}
+}
+
 func main() {
 	rl := NewRateLimiter(5, 2) // 5 req/s, burst 2
-	defer rl.Stop()
 
 	ctx := context.Background()
 	start := time.Now()
 	for i := 1; i <= 10; i++ {
-		_ = rl.Acquire(ctx)
+		_ = rl.Wait(ctx)
 		fmt.Println("request", i, "at", time.Since(start).Truncate(10*time.Millisecond))
 	}
+
+	// fractional rate driven by a fake clock: one event every two seconds
+	clk := clock.NewFake(time.Now())
+	slow := NewRateLimiter(0.5, 1, WithClock(clk))
+	fmt.Println("allow:", slow.Allow(), slow.Allow())
+	fmt.Println("reserve delay:", slow.Reserve().Delay())
+	clk.Advance(4 * time.Second)
+	fmt.Println("after 4s allow:", slow.Allow())
+
+	slow.SetLimit(Every(100 * time.Millisecond))
+	slow.SetBurst(5)
+	clk.Advance(time.Second)
+	fmt.Println("after SetLimit/SetBurst allowN(5):", slow.AllowN(5))
+
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	fmt.Println("waitN(2) with short deadline:", rl.WaitN(shortCtx, 2))
+	fmt.Println("waitN(3) over burst:", rl.WaitN(ctx, 3))
+
+	keyedDemo(clk)
+	compareAlgorithms()
+	distributedDemo()
+	httpDemo()
+}
+
+func keyedDemo(clk *clock.Fake) {
+	kl := NewKeyedLimiter(1, 2,
+		WithShards(4),
+		WithIdleTTL(time.Minute),
+		WithMaxKeys(100),
+		WithKeyedClock(clk),
+	)
+
+	for _, key := range []string{"api-key-a", "api-key-a", "api-key-a", "10.0.0.7"} {
+		fmt.Println("keyed allow", key, kl.Allow(key))
+	}
+	fmt.Println("tracked keys:", kl.Len())
+
+	clk.Advance(2 * time.Minute)
+	fmt.Println("evicted idle:", kl.EvictIdle(), "tracked keys:", kl.Len())
+}
+
+func compareAlgorithms() {
+	// 5 events at the end of one second and 5 more just after the edge
+	for _, name := range []string{"token bucket", "sliding log", "sliding counter", "gcra"} {
+		clk := clock.NewFake(time.Unix(1700000000, 0))
+		var l Limiter
+		switch name {
+		case "token bucket":
+			l = NewRateLimiter(5, 5, WithClock(clk))
+		case "sliding log":
+			l = NewSlidingWindowLog(5, time.Second, WithClock(clk))
+		case "sliding counter":
+			l = NewSlidingWindowCounter(5, time.Second, WithClock(clk))
+		case "gcra":
+			l = NewGCRA(5, 5, WithClock(clk))
+		}
+
+		admitted := 0
+		clk.Advance(900 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			if l.Allow() {
+				admitted++
+			}
+		}
+		clk.Advance(200 * time.Millisecond)
+		for i := 0; i < 5; i++ {
+			if l.Allow() {
+				admitted++
+			}
+		}
+		fmt.Printf("%-15s admitted %d of 10 across the edge\n", name, admitted)
+	}
+}
+
+func distributedDemo() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("listen:", err)
+		return
+	}
+	go ServeRESP(ln, NewMemoryStore())
+
+	// two replicas share a burst of 4 through the stand-in server
+	store := NewRESPStore(ln.Addr().String())
+	defer store.Close()
+	replicas := []*DistributedLimiter{
+		NewDistributedLimiter(store, "quota:search", 1, 4, NewRateLimiter(0.5, 2)),
+		NewDistributedLimiter(store, "quota:search", 1, 4, NewRateLimiter(0.5, 2)),
+	}
+
+	admitted := 0
+	for i := 0; i < 6; i++ {
+		if replicas[i%2].Allow() {
+			admitted++
+		}
+	}
+	fmt.Println("shared quota admitted:", admitted, "of 6")
+
+	// store goes away: each replica falls back to its local share
+	_ = ln.Close()
+	_ = store.Close()
+	fmt.Println("store down, allow:", replicas[0].Allow(), "fallbacks:", replicas[0].Fallbacks())
+
+	// during the cool-down the store is not tried, so fallbacks are immediate
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		replicas[0].Allow()
+	}
+	fmt.Println("5 more calls took", time.Since(start).Round(time.Millisecond), "fallbacks:", replicas[0].Fallbacks())
+}
+
+func httpDemo() {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "ok") })
+	srv := httptest.NewServer(RateLimitMiddleware(NewRateLimiter(1, 2), ok))
+	defer srv.Close()
+
+	for i := 1; i <= 3; i++ {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			fmt.Println("get:", err)
+			return
+		}
+		resp.Body.Close()
+		fmt.Println("http", i, resp.Status,
+			"remaining=", resp.Header.Get("RateLimit-Remaining"),
+			"retry-after=", resp.Header.Get("Retry-After"))
+	}
+
+	// client side: pace requests instead of hitting 429s
+	upstream := httptest.NewServer(ok)
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &RateLimitedTransport{Limiter: NewRateLimiter(20, 1)}}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if resp, err := client.Get(upstream.URL); err == nil {
+			resp.Body.Close()
+		}
+	}
+	fmt.Println("paced client took", time.Since(start).Truncate(10*time.Millisecond))
 }
@@ -0,0 +1,284 @@
+// resp.go
+//
+// A Store backed by a Redis-compatible server, speaking RESP over TCP, plus a
+// small stand-in server so the example runs without Redis.
+//
+//   - The refill-and-take step runs server-side as a Lua script via EVAL
+//   - The stand-in answers PING and that one script using a MemoryStore
+//
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// takeScript mirrors MemoryStore.Take. KEYS[1] = bucket key;
+// ARGV = rate per second, burst, n. Time comes from the server, not the caller.
+const takeScript = `
+local rate, burst, n = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens, last = tonumber(b[1]) or burst, tonumber(b[2]) or now
+if now > last then tokens = math.min(burst, tokens + (now - last) / 1000 * rate); last = now end
+local ok, wait = 0, -1
+if n <= burst then
+  if tokens >= n then tokens = tokens - n; ok = 1; wait = 0
+  elseif rate > 0 then wait = math.ceil((n - tokens) / rate * 1000) end
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', last)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / math.max(rate, 0.001) * 1000) + 1000)
+return {ok, wait}
+`
+
+// RESPStore keeps a small pool of idle connections. Each call takes one (or
+// dials), so concurrent callers do not wait on each other's round trips; a
+// connection that hits an I/O error is closed rather than returned.
+type RESPStore struct {
+	addr    string
+	maxIdle int
+
+	mu   sync.Mutex
+	idle []*respConn
+}
+
+type respConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+func NewRESPStore(addr string) *RESPStore { return &RESPStore{addr: addr, maxIdle: 4} }
+
+func (s *RESPStore) Take(ctx context.Context, key string, r Limit, burst, n int) (bool, time.Duration, error) {
+	reply, err := s.do(ctx,
+		"EVAL", takeScript, "1", key,
+		strconv.FormatFloat(float64(r), 'g', -1, 64),
+		strconv.Itoa(burst),
+		strconv.Itoa(n),
+	)
+	if err != nil {
+		return false, 0, err
+	}
+
+	arr, ok := reply.([]any)
+	if !ok || len(arr) != 2 {
+		return false, 0, fmt.Errorf("resp: unexpected reply %v", reply)
+	}
+	allowed, _ := arr[0].(int64)
+	waitMs, _ := arr[1].(int64)
+
+	wait := InfDuration
+	if waitMs >= 0 {
+		wait = time.Duration(waitMs) * time.Millisecond
+	}
+	return allowed == 1, wait, nil
+}
+
+// Close closes the idle connections; later calls dial again.
+func (s *RESPStore) Close() error {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for _, c := range idle {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *RESPStore) do(ctx context.Context, args ...string) (any, error) {
+	c, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = c.SetDeadline(deadline)
+	} else {
+		_ = c.SetDeadline(time.Time{})
+	}
+
+	if _, err := c.Write(encodeCommand(args)); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	reply, err := readReply(c.rd)
+	var respErr respError
+	if err != nil && !errors.As(err, &respErr) {
+		_ = c.Close()
+		return nil, err
+	}
+	s.put(c)
+	return reply, err
+}
+
+func (s *RESPStore) get(ctx context.Context) (*respConn, error) {
+	s.mu.Lock()
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, nil
+	}
+	s.mu.Unlock()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &respConn{Conn: conn, rd: bufio.NewReader(conn)}, nil
+}
+
+func (s *RESPStore) put(c *respConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.idle) >= s.maxIdle {
+		_ = c.Close()
+		return
+	}
+	s.idle = append(s.idle, c)
+}
+
+type respError string
+
+func (e respError) Error() string { return "resp: " + string(e) }
+
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return []byte(b.String())
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// readReply decodes one RESP value: simple strings, errors, integers, bulk
+// strings and arrays.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("resp: empty line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, respError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("resp: unknown reply type %q", line[0])
+	}
+}
+
+// ServeRESP is a stand-in for Redis: it accepts connections on ln until it is
+// closed and evaluates the take script against store, on the store's clock.
+func ServeRESP(ln net.Listener, store Store) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go serveRESPConn(conn, store)
+	}
+}
+
+func serveRESPConn(conn net.Conn, store Store) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		req, err := readReply(rd)
+		if err != nil {
+			return
+		}
+		args, _ := req.([]any)
+		if _, err := conn.Write(handleRESP(args, store)); err != nil {
+			return
+		}
+	}
+}
+
+func handleRESP(args []any, store Store) []byte {
+	str := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		s, _ := args[i].(string)
+		return s
+	}
+
+	switch strings.ToUpper(str(0)) {
+	case "PING":
+		return []byte("+PONG\r\n")
+	case "EVAL":
+		if str(1) != takeScript || len(args) != 7 {
+			return []byte("-ERR stand-in only evaluates the take script\r\n")
+		}
+		r, err1 := strconv.ParseFloat(str(4), 64)
+		burst, err2 := strconv.Atoi(str(5))
+		n, err3 := strconv.Atoi(str(6))
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return []byte("-ERR bad arguments\r\n")
+		}
+
+		ok, wait, err := store.Take(context.Background(), str(3), Limit(r), burst, n)
+		if err != nil {
+			return []byte("-ERR " + err.Error() + "\r\n")
+		}
+		allowed, waitMs := 0, int64(-1)
+		if ok {
+			allowed = 1
+		}
+		if wait != InfDuration {
+			waitMs = (wait + time.Millisecond - 1).Milliseconds()
+		}
+		return []byte(fmt.Sprintf("*2\r\n:%d\r\n:%d\r\n", allowed, waitMs))
+	default:
+		return []byte("-ERR unknown command\r\n")
+	}
+}
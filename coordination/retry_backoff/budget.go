@@ -0,0 +1,109 @@
+// budget.go
+//
+// A retry budget caps retries as a fraction of recent successful calls, so a
+// dependency that is down does not see every caller multiply its load.
+//
+//   - Each success deposits ratio tokens; each retry withdraws one
+//   - A minimum per-second allowance keeps low-traffic callers able to retry
+//   - Deposits and withdrawals expire after a sliding window
+//
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-patterns-examples/internal/clock"
+)
+
+// ErrBudgetExhausted is the RetryError cause when the budget refuses a retry.
+var ErrBudgetExhausted = errors.New("retry budget exhausted")
+
+type budgetBucket struct {
+	epoch       int64
+	deposits    int
+	withdrawals int
+}
+
+type RetryBudget struct {
+	mu      sync.Mutex
+	ratio   float64
+	reserve float64 // minimum retries available per window
+	width   time.Duration
+	buckets []budgetBucket
+	clock   clock.Clock
+
+	suppressed atomic.Uint64
+}
+
+type BudgetOption func(*RetryBudget)
+
+func WithBudgetClock(c clock.Clock) BudgetOption { return func(b *RetryBudget) { b.clock = c } }
+
+// NewRetryBudget allows ratio retries per successful call made within window,
+// plus minPerSecond retries per second regardless of traffic.
+func NewRetryBudget(ratio, minPerSecond float64, window time.Duration, opts ...BudgetOption) *RetryBudget {
+	const buckets = 10
+	b := &RetryBudget{
+		ratio:   ratio,
+		reserve: minPerSecond * window.Seconds(),
+		width:   window / buckets,
+		buckets: make([]budgetBucket, buckets),
+		clock:   clock.Real{},
+	}
+	if b.width <= 0 {
+		b.width = time.Millisecond
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *RetryBudget) bucket(now time.Time) *budgetBucket {
+	e := now.UnixNano() / int64(b.width)
+	bk := &b.buckets[e%int64(len(b.buckets))]
+	if bk.epoch != e {
+		*bk = budgetBucket{epoch: e}
+	}
+	return bk
+}
+
+func (b *RetryBudget) balance(now time.Time) float64 {
+	e := now.UnixNano() / int64(b.width)
+	oldest := e - int64(len(b.buckets)) + 1
+
+	bal := b.reserve
+	for _, bk := range b.buckets {
+		if bk.epoch >= oldest && bk.epoch <= e {
+			bal += b.ratio*float64(bk.deposits) - float64(bk.withdrawals)
+		}
+	}
+	return bal
+}
+
+// Deposit records a successful call.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket(b.clock.Now()).deposits++
+}
+
+// TryWithdraw reports whether a retry may proceed, spending one token if so.
+func (b *RetryBudget) TryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	if b.balance(now) < 1 {
+		b.suppressed.Add(1)
+		return false
+	}
+	b.bucket(now).withdrawals++
+	return true
+}
+
+// Suppressed returns how many retries the budget has refused.
+func (b *RetryBudget) Suppressed() uint64 { return b.suppressed.Load() }
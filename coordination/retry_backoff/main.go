@@ -4,8 +4,13 @@
 //
 // Key ideas illustrated:
 //
-//   - Retry loop with max attempts
-//   - Exponential backoff with random jitter
+//   - Retry loop with max attempts and a max elapsed time
+//   - Pluggable backoff strategies (constant, exponential, full/equal/decorrelated jitter)
+//   - A classifier decides which errors are worth retrying
+//   - Each attempt gets its own timeout derived from the caller's context
+//   - A shared retry budget prevents retry storms against a failing dependency
+//   - Generic Retry returns a value, or an error describing every attempt
+//   - Server-provided retry-after hints override the backoff delay
 //   - Respect context cancellation
 //
 package main
@@ -15,49 +20,304 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
+
+	"go-patterns-examples/internal/backoff"
+	"go-patterns-examples/internal/clock"
 )
 
-func sometimesFails() error {
-	if rand.Intn(4) != 0 { // succeed ~25%
-		return errors.New("transient error")
+type Retrier struct {
+	maxAttempts    int
+	maxElapsed     time.Duration
+	attemptTimeout time.Duration
+	backoff        backoff.Strategy
+	retryable      func(error) bool
+	onRetry        func(attempt int, err error, delay time.Duration)
+	budget         *RetryBudget
+	clock          clock.Clock
+}
+
+type Option func(*Retrier)
+
+// WithMaxAttempts bounds the total number of calls; 0 means unlimited.
+func WithMaxAttempts(n int) Option { return func(r *Retrier) { r.maxAttempts = n } }
+
+// WithMaxElapsed stops retrying once the next wait would end past d from the
+// first attempt.
+func WithMaxElapsed(d time.Duration) Option { return func(r *Retrier) { r.maxElapsed = d } }
+
+// WithAttemptTimeout gives each attempt a context that expires after d (or
+// earlier, if the caller's context does).
+func WithAttemptTimeout(d time.Duration) Option { return func(r *Retrier) { r.attemptTimeout = d } }
+
+func WithBackoff(s backoff.Strategy) Option { return func(r *Retrier) { r.backoff = s } }
+
+// WithRetryable decides which errors are retried; others are returned at once.
+func WithRetryable(fn func(error) bool) Option { return func(r *Retrier) { r.retryable = fn } }
+
+// WithOnRetry is called before each wait, with the attempt that just failed.
+func WithOnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(r *Retrier) { r.onRetry = fn }
+}
+
+// WithBudget consults a (typically shared) retry budget before every retry.
+func WithBudget(b *RetryBudget) Option { return func(r *Retrier) { r.budget = b } }
+
+func WithClock(c clock.Clock) Option { return func(r *Retrier) { r.clock = c } }
+
+func NewRetrier(opts ...Option) *Retrier {
+	r := &Retrier{
+		maxAttempts: 3,
+		backoff:     backoff.Exponential{Base: 100 * time.Millisecond, Max: 800 * time.Millisecond, Jitter: 0.5},
+		retryable:   func(error) bool { return true },
+		clock:       clock.Real{},
 	}
-	return nil
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// AttemptError records one failed attempt.
+type AttemptError struct {
+	Attempt  int
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+}
+
+func (e AttemptError) Error() string {
+	return fmt.Sprintf("attempt %d (%s): %v", e.Attempt, e.Duration.Truncate(time.Millisecond), e.Err)
 }
 
-func retry(ctx context.Context, max int, base time.Duration, fn func() error) error {
-	backoff := base
-	for attempt := 1; attempt <= max; attempt++ {
-		err := fn()
+func (e AttemptError) Unwrap() error { return e.Err }
+
+// RetryError is returned when retrying gives up. errors.Is and errors.As look
+// through every attempt's error as well as Cause.
+type RetryError struct {
+	Attempts []AttemptError
+	Cause    error // why retrying stopped early, e.g. the context ended; nil otherwise
+}
+
+func (e *RetryError) Error() string {
+	msg := fmt.Sprintf("retry: gave up after %d attempt(s)", len(e.Attempts))
+	if last := e.Last(); last != nil {
+		msg += ": " + last.Error()
+	}
+	if e.Cause != nil {
+		msg += " (" + e.Cause.Error() + ")"
+	}
+	return msg
+}
+
+// Last returns the error of the final attempt.
+func (e *RetryError) Last() error {
+	if len(e.Attempts) == 0 {
+		return nil
+	}
+	return e.Attempts[len(e.Attempts)-1].Err
+}
+
+func (e *RetryError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Attempts)+1)
+	for _, a := range e.Attempts {
+		errs = append(errs, a)
+	}
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	return errs
+}
+
+// RetryAfterer is implemented by errors carrying a server-provided hint, such
+// as an HTTP Retry-After header. A positive hint replaces the backoff delay.
+type RetryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// Do is Retry for functions without a result.
+func (r *Retrier) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := Retry(ctx, r, func(ctx context.Context, _ int) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Retry calls fn until it succeeds, returns a non-retryable error, the attempt
+// or time budget runs out, or ctx ends. Attempts are numbered from 1. On
+// failure it returns a *RetryError describing every attempt.
+func Retry[T any](ctx context.Context, r *Retrier, fn func(ctx context.Context, attempt int) (T, error)) (T, error) {
+	var zero T
+	var failed []AttemptError
+	giveUp := func(cause error) (T, error) {
+		return zero, &RetryError{Attempts: failed, Cause: cause}
+	}
+
+	start := r.clock.Now()
+	var delay time.Duration
+
+	for attempt := 1; ; attempt++ {
+		began := r.clock.Now()
+		v, err := runAttempt(ctx, r, attempt, fn)
 		if err == nil {
-			return nil
+			if r.budget != nil {
+				r.budget.Deposit()
+			}
+			return v, nil
 		}
+		failed = append(failed, AttemptError{Attempt: attempt, Err: err, Start: began, Duration: r.clock.Since(began)})
 
-		if attempt == max {
-			return err
+		if ctx.Err() != nil {
+			return giveUp(ctx.Err())
+		}
+		if !r.retryable(err) {
+			return giveUp(nil)
+		}
+		if r.maxAttempts > 0 && attempt >= r.maxAttempts {
+			return giveUp(nil)
 		}
 
-		jitter := time.Duration(rand.Intn(100)) * time.Millisecond
-		sleep := backoff + jitter
+		delay = r.backoff.Next(attempt-1, delay)
+		var hint RetryAfterer
+		if errors.As(err, &hint) && hint.RetryAfter() > 0 {
+			delay = hint.RetryAfter()
+		}
+		if r.maxElapsed > 0 && r.clock.Since(start)+delay > r.maxElapsed {
+			return giveUp(nil)
+		}
+		if r.budget != nil && !r.budget.TryWithdraw() {
+			return giveUp(ErrBudgetExhausted)
+		}
+		if r.onRetry != nil {
+			r.onRetry(attempt, err, delay)
+		}
 
+		t := r.clock.NewTimer(delay)
 		select {
-		case <-time.After(sleep):
+		case <-t.C():
 		case <-ctx.Done():
-			return ctx.Err()
+			t.Stop()
+			return giveUp(ctx.Err())
 		}
+	}
+}
 
-		if backoff < 800*time.Millisecond {
-			backoff *= 2
-		}
+func runAttempt[T any](ctx context.Context, r *Retrier, attempt int, fn func(ctx context.Context, attempt int) (T, error)) (T, error) {
+	if r.attemptTimeout <= 0 {
+		return fn(ctx, attempt)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.attemptTimeout)
+	defer cancel()
+	return fn(ctx, attempt)
+}
+
+var errNotFound = errors.New("not found")
+
+func sometimesFails(ctx context.Context) error {
+	if rand.Intn(4) != 0 { // succeed ~25%
+		return errors.New("transient error")
+	}
+	return nil
+}
+
+// slowSometimes hangs on some attempts; the per-attempt timeout cuts them short.
+func slowSometimes(ctx context.Context) error {
+	if rand.Intn(2) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
 	}
 	return nil
 }
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
 
-	err := retry(ctx, 6, 100*time.Millisecond, sometimesFails)
-	fmt.Println("final err:", err)
+	// each demo gets its own deadline so a long first run cannot starve the rest
+	do := func(r *Retrier, op func(context.Context) error) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		return r.Do(ctx, op)
+	}
+
+	logRetry := WithOnRetry(func(attempt int, err error, delay time.Duration) {
+		fmt.Println("  attempt", attempt, "failed:", err, "- retrying in", delay.Truncate(time.Millisecond))
+	})
+
+	r := NewRetrier(WithMaxAttempts(6), logRetry)
+	fmt.Println("final err:", do(r, sometimesFails))
+
+	r = NewRetrier(
+		WithMaxAttempts(0),
+		WithMaxElapsed(time.Second),
+		WithAttemptTimeout(50*time.Millisecond),
+		WithBackoff(backoff.Decorrelated{Base: 20 * time.Millisecond, Max: 200 * time.Millisecond}),
+		logRetry,
+	)
+	fmt.Println("slow op err:", do(r, slowSometimes))
+
+	r = NewRetrier(
+		WithBackoff(backoff.Constant(10*time.Millisecond)),
+		WithRetryable(func(err error) bool { return !errors.Is(err, errNotFound) }),
+		logRetry,
+	)
+	err := do(r, func(ctx context.Context) error { return fmt.Errorf("user 7: %w", errNotFound) })
+	fmt.Println("non-retryable err:", err)
+
+	budgetDemo()
+	genericDemo()
+}
+
+// throttledError is what a client might build from a 429 with Retry-After.
+type throttledError struct{ after time.Duration }
+
+func (e throttledError) Error() string             { return "throttled" }
+func (e throttledError) RetryAfter() time.Duration { return e.after }
+
+func genericDemo() {
+	r := NewRetrier(WithMaxAttempts(3), WithBackoff(backoff.Constant(time.Millisecond)))
+
+	price, err := Retry(context.Background(), r, func(ctx context.Context, attempt int) (float64, error) {
+		if attempt == 1 {
+			return 0, throttledError{after: 30 * time.Millisecond}
+		}
+		return 9.99, nil
+	})
+	fmt.Println("price:", price, "err:", err)
+
+	_, err = Retry(context.Background(), r, func(ctx context.Context, attempt int) (string, error) {
+		return "", fmt.Errorf("shard %d: %w", attempt, errNotFound)
+	})
+	var re *RetryError
+	if errors.As(err, &re) {
+		for _, a := range re.Attempts {
+			fmt.Println("  ", a)
+		}
+	}
+	fmt.Println("is not found:", errors.Is(err, errNotFound))
+}
+
+func budgetDemo() {
+	ctx := context.Background()
+
+	// 10% retries on top of successes, at least 5 retries per second
+	budget := NewRetryBudget(0.1, 5, time.Second)
+	r := NewRetrier(
+		WithMaxAttempts(4),
+		WithBackoff(backoff.Constant(time.Millisecond)),
+		WithBudget(budget),
+	)
+
+	down := func(ctx context.Context) error { return errors.New("dependency down") }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = r.Do(ctx, down)
+		}()
+	}
+	wg.Wait()
+	fmt.Println("20 callers against a down dependency, retries suppressed by budget:", budget.Suppressed())
 }
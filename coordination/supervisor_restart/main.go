@@ -1,64 +1,115 @@
 // supervisor_restart_strategy.go
 //
-// This example demonstrates a simple supervisor that restarts a worker when it fails.
+// This example demonstrates a supervisor that restarts workers when they fail.
 //
 // Key ideas illustrated:
 //
-//   - A supervisor loop runs the child worker
+//   - A supervisor runs named children and restarts them per strategy
+//     (one-for-one, one-for-all, rest-for-one) and restart type
 //   - On error, it waits (backoff) and restarts
+//   - Too many restarts in a window escalate to the parent supervisor
+//   - Panics are recovered and reported as crash events
+//   - Services expose readiness, aggregated into a health endpoint
 //   - Stops when parent context is canceled
 //
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"time"
+
+	"go-patterns-examples/internal/backoff"
 )
 
-func flakyWorker(ctx context.Context) error {
-	// do some "work"
-	select {
-	case <-time.After(120 * time.Millisecond):
-	case <-ctx.Done():
-		return ctx.Err()
+func flakyWorker(name string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for {
+			// do some "work"
+			select {
+			case <-time.After(120 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			// randomly fail, sometimes badly
+			switch rand.Intn(6) {
+			case 0:
+				return errors.New(name + " crashed")
+			case 1:
+				var m map[string]int
+				m[name]++ // panics: assignment to entry in nil map
+			}
+			fmt.Println(name + ": completed one cycle")
+		}
+	}
+}
+
+// migration fails on its first run and succeeds on the retry.
+func migration() func(ctx context.Context) error {
+	runs := 0
+	return func(ctx context.Context) error {
+		runs++
+		if runs == 1 {
+			return errors.New("migration: lock held")
+		}
+		fmt.Println("migration: done")
+		return nil
 	}
+}
+
+// apiService warms up before it is ready and later degrades for a while.
+type apiService struct {
+	started time.Time
+}
 
-	// randomly fail
-	if rand.Intn(3) == 0 {
-		return errors.New("worker crashed")
+func (a *apiService) Start(ctx context.Context) error {
+	a.started = time.Now()
+	fmt.Println("api: started")
+	return nil
+}
+
+func (a *apiService) Ready(ctx context.Context) error {
+	switch up := time.Since(a.started); {
+	case up < 150*time.Millisecond:
+		return errors.New("warming cache")
+	case up > 500*time.Millisecond && up < 800*time.Millisecond:
+		return errors.New("db latency high")
 	}
-	fmt.Println("worker: completed one cycle")
 	return nil
 }
 
-func supervise(ctx context.Context) {
-	backoff := 100 * time.Millisecond
+func (a *apiService) Stop(ctx context.Context) error {
+	fmt.Println("api: stopped")
+	return nil
+}
+
+func pollHealth(ctx context.Context, url string) {
+	t := time.NewTicker(200 * time.Millisecond)
+	defer t.Stop()
 	for {
 		select {
+		case <-t.C:
 		case <-ctx.Done():
-			fmt.Println("supervisor: stop")
 			return
-		default:
 		}
-
-		err := flakyWorker(ctx)
-		if err == nil {
-			backoff = 100 * time.Millisecond
+		resp, err := http.Get(url)
+		if err != nil {
 			continue
 		}
-		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-			fmt.Println("supervisor: worker ended:", err)
-			return
-		}
-
-		fmt.Println("supervisor: restart after error:", err)
-		time.Sleep(backoff)
-		if backoff < 800*time.Millisecond {
-			backoff *= 2
+		var report HealthReport
+		err = json.NewDecoder(resp.Body).Decode(&report)
+		resp.Body.Close()
+		if err != nil || len(report.Services) == 0 {
+			fmt.Println("health:", resp.StatusCode, "unreadable report:", err)
+			continue
 		}
+		fmt.Println("health:", resp.StatusCode, report.Status, "api:", report.Services[0].Status, report.Services[0].Error)
 	}
 }
 
@@ -67,5 +118,42 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
 	defer cancel()
 
-	supervise(ctx)
+	restartDelay := WithBackoff(backoff.Exponential{Base: 100 * time.Millisecond, Max: 800 * time.Millisecond})
+
+	// the cache depends on the connection: rest-for-one restarts it too.
+	// Both are launched together, so the cache copes with conn not being up.
+	db := NewSupervisor("db", RestForOne, WithIntensity(2, time.Second), restartDelay)
+	db.Add(ChildSpec{Name: "conn", Run: flakyWorker("conn"), Restart: Permanent})
+	db.Add(ChildSpec{Name: "cache", Run: flakyWorker("cache"), Restart: Permanent})
+
+	root := NewSupervisor("root", OneForOne, WithIntensity(5, time.Second), restartDelay)
+	root.Add(ChildSpec{Name: "migration", Run: migration(), Restart: Transient})
+	root.AddSupervisor(db, Permanent)
+	root.AddService("api", &apiService{}, Permanent,
+		WithStartupTimeout(time.Second),
+		WithProbeInterval(50*time.Millisecond),
+	)
+
+	srv := httptest.NewServer(root.HealthHandler())
+	defer srv.Close()
+	go pollHealth(ctx, srv.URL)
+	root.Add(ChildSpec{Name: "worker", Run: flakyWorker("worker"), Restart: Permanent})
+
+	for _, sup := range []*Supervisor{root, db} {
+		go func(events <-chan Event) {
+			for ev := range events {
+				fmt.Println("event:", ev)
+				var pe *PanicError
+				if errors.As(ev.Err, &pe) && ev.Kind == ChildCrashed {
+					fmt.Printf("  stack: %d bytes captured\n", len(pe.Stack))
+				}
+			}
+		}(sup.Subscribe(ctx, 16))
+	}
+
+	err := root.Run(ctx)
+	fmt.Println("supervisor: stop:", err)
+	if errors.Is(err, ErrMaxRestarts) {
+		fmt.Println("supervisor: escalated")
+	}
 }
@@ -0,0 +1,281 @@
+// service.go
+//
+// Long-running services hosted by a supervisor, with health tracking.
+//
+//   - A Service is started, probed for readiness, and stopped
+//   - Startup must reach ready within a timeout, or the child crashes
+//   - A failing probe marks the service degraded; optionally too many failures
+//     in a row crash it so the supervisor restarts it
+//   - Health aggregates every service (and nested supervisor) into one report
+//
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// Service is a component with an explicit lifecycle. Start returns once the
+// service is running, and its ctx lasts as long as the child, so background
+// work may be tied to it; Ready reports whether it can take traffic; Stop
+// shuts it down within ctx.
+type Service interface {
+	Start(ctx context.Context) error
+	Ready(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Waiter may be implemented by a Service that can exit on its own; a value
+// on Done ends the child with that error (nil for a clean exit).
+type Waiter interface {
+	Done() <-chan error
+}
+
+type Status string
+
+const (
+	StatusReady    Status = "ready"
+	StatusDegraded Status = "degraded"
+	StatusStarting Status = "starting"
+	StatusStopped  Status = "stopped"
+)
+
+// severity orders statuses from best to worst for aggregation.
+func (s Status) severity() int {
+	switch s {
+	case StatusReady:
+		return 0
+	case StatusDegraded:
+		return 1
+	case StatusStarting:
+		return 2
+	default:
+		return 3
+	}
+}
+
+type ServiceHealth struct {
+	Name   string    `json:"name"`
+	Status Status    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+type HealthReport struct {
+	Name     string          `json:"name"`
+	Status   Status          `json:"status"`
+	Services []ServiceHealth `json:"services,omitempty"`
+	Nested   []HealthReport  `json:"supervisors,omitempty"`
+}
+
+type serviceConfig struct {
+	startupTimeout   time.Duration
+	probeInterval    time.Duration
+	stopTimeout      time.Duration
+	failureThreshold int
+}
+
+type ServiceOption func(*serviceConfig)
+
+// WithStartupTimeout bounds the wait for the first successful probe after Start.
+func WithStartupTimeout(d time.Duration) ServiceOption {
+	return func(c *serviceConfig) { c.startupTimeout = d }
+}
+
+// WithProbeInterval sets how often Ready is called; non-positive values keep
+// the default of one second.
+func WithProbeInterval(d time.Duration) ServiceOption {
+	return func(c *serviceConfig) { c.probeInterval = d }
+}
+
+func WithStopTimeout(d time.Duration) ServiceOption {
+	return func(c *serviceConfig) { c.stopTimeout = d }
+}
+
+// WithFailureThreshold crashes the service after n consecutive failed probes;
+// 0 only marks it degraded.
+func WithFailureThreshold(n int) ServiceOption {
+	return func(c *serviceConfig) { c.failureThreshold = n }
+}
+
+// AddService registers svc as a child and tracks its health.
+func (s *Supervisor) AddService(name string, svc Service, restart Restart, opts ...ServiceOption) {
+	cfg := serviceConfig{
+		startupTimeout: 5 * time.Second,
+		probeInterval:  time.Second,
+		stopTimeout:    5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.probeInterval <= 0 {
+		cfg.probeInterval = time.Second
+	}
+
+	s.setHealth(name, StatusStopped, nil)
+	s.Add(ChildSpec{
+		Name:    name,
+		Restart: restart,
+		Run:     func(ctx context.Context) error { return s.runService(ctx, name, svc, cfg) },
+	})
+}
+
+// AddSupervisor nests child under s and includes it in s's health report.
+func (s *Supervisor) AddSupervisor(child *Supervisor, restart Restart) {
+	s.mu.Lock()
+	s.nested = append(s.nested, child)
+	s.mu.Unlock()
+	s.Add(child.Spec(restart))
+}
+
+func (s *Supervisor) setHealth(name string, st Status, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.health[name]
+	if ok && h.Status == st && (err == nil) == (h.Error == "") {
+		return
+	}
+	h = ServiceHealth{Name: name, Status: st, Since: s.clock.Now()}
+	if err != nil {
+		h.Error = err.Error()
+	}
+	s.health[name] = h
+}
+
+func (s *Supervisor) runService(ctx context.Context, name string, svc Service, cfg serviceConfig) error {
+	s.setHealth(name, StatusStarting, nil)
+	defer func() {
+		// The supervisor turns the panic into a crash; record it here too so
+		// health does not report a dead service as ready.
+		if r := recover(); r != nil {
+			s.setHealth(name, StatusStopped, &PanicError{Value: r, Stack: debug.Stack()})
+			panic(r)
+		}
+	}()
+
+	stop := func(cause error) {
+		stopCtx, cancel := context.WithTimeout(context.Background(), cfg.stopTimeout)
+		defer cancel()
+		if err := svc.Stop(stopCtx); err != nil && cause == nil {
+			cause = err
+		}
+		s.setHealth(name, StatusStopped, cause)
+	}
+
+	if err := svc.Start(ctx); err != nil {
+		s.setHealth(name, StatusStopped, err)
+		return fmt.Errorf("%s: start: %w", name, err)
+	}
+	readyCtx, cancel := context.WithTimeout(ctx, cfg.startupTimeout)
+	defer cancel()
+	if err := s.awaitReady(readyCtx, svc, cfg.probeInterval); err != nil {
+		err = fmt.Errorf("%s: not ready within %s: %w", name, cfg.startupTimeout, err)
+		stop(err)
+		return err
+	}
+	s.setHealth(name, StatusReady, nil)
+
+	var done <-chan error
+	if w, ok := svc.(Waiter); ok {
+		done = w.Done()
+	}
+
+	t := s.clock.NewTicker(cfg.probeInterval)
+	defer t.Stop()
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			stop(nil)
+			return ctx.Err()
+		case err := <-done:
+			s.setHealth(name, StatusStopped, err)
+			return err
+		case <-t.C():
+			err := probe(ctx, svc, cfg.probeInterval)
+			if err == nil {
+				failures = 0
+				s.setHealth(name, StatusReady, nil)
+				continue
+			}
+			failures++
+			s.setHealth(name, StatusDegraded, err)
+			if cfg.failureThreshold > 0 && failures >= cfg.failureThreshold {
+				err = fmt.Errorf("%s: unhealthy after %d failed probes: %w", name, failures, err)
+				stop(err)
+				return err
+			}
+		}
+	}
+}
+
+// awaitReady probes until the service is ready or ctx (the startup timeout)
+// ends, returning the last probe error in that case.
+func (s *Supervisor) awaitReady(ctx context.Context, svc Service, every time.Duration) error {
+	t := s.clock.NewTicker(every)
+	defer t.Stop()
+	for {
+		err := probe(ctx, svc, every)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-t.C():
+		}
+	}
+}
+
+func probe(ctx context.Context, svc Service, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return svc.Ready(ctx)
+}
+
+// Health returns the status of every service under s and its nested
+// supervisors; the overall status is the worst of them.
+func (s *Supervisor) Health() HealthReport {
+	s.mu.Lock()
+	r := HealthReport{Name: s.name, Status: StatusReady}
+	for _, h := range s.health {
+		r.Services = append(r.Services, h)
+	}
+	nested := append([]*Supervisor(nil), s.nested...)
+	s.mu.Unlock()
+
+	sort.Slice(r.Services, func(i, j int) bool { return r.Services[i].Name < r.Services[j].Name })
+	for _, h := range r.Services {
+		if h.Status.severity() > r.Status.severity() {
+			r.Status = h.Status
+		}
+	}
+	for _, n := range nested {
+		nr := n.Health()
+		r.Nested = append(r.Nested, nr)
+		if nr.Status.severity() > r.Status.severity() {
+			r.Status = nr.Status
+		}
+	}
+	return r
+}
+
+// HealthHandler serves Health as JSON: 200 when ready or degraded, 503 otherwise.
+func (s *Supervisor) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := s.Health()
+		code := http.StatusOK
+		if report.Status.severity() > StatusDegraded.severity() {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
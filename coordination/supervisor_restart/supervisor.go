@@ -0,0 +1,390 @@
+// supervisor.go
+//
+// An Erlang-style supervisor: it starts named children, restarts them
+// according to a strategy, and gives up (escalates) when they restart too often.
+//
+//   - OneForOne restarts only the child that exited
+//   - OneForAll restarts every child when one exits
+//   - RestForOne restarts the child and those started after it
+//   - Permanent children always restart, Transient only after an error,
+//     Temporary never
+//   - A supervisor's Run can itself be a child, forming a tree
+//   - A panicking child is recovered and treated as a crash
+//   - Crashes, restarts and escalations are published to subscribers
+//   - Services (service.go) add lifecycle, readiness and health reporting
+//
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go-patterns-examples/internal/backoff"
+	"go-patterns-examples/internal/clock"
+)
+
+type Strategy int
+
+const (
+	OneForOne Strategy = iota
+	OneForAll
+	RestForOne
+)
+
+type Restart int
+
+const (
+	Permanent Restart = iota
+	Transient
+	Temporary
+)
+
+type ChildSpec struct {
+	Name    string
+	Run     func(ctx context.Context) error
+	Restart Restart
+}
+
+var ErrMaxRestarts = errors.New("supervisor: restart intensity exceeded")
+
+// EscalationError is returned by Run when children restart more than the
+// allowed number of times within the window. It matches ErrMaxRestarts and
+// the child's last error with errors.Is.
+type EscalationError struct {
+	Supervisor string
+	Child      string
+	Err        error
+}
+
+func (e *EscalationError) Error() string {
+	return fmt.Sprintf("supervisor %s: too many restarts, last from %s: %v", e.Supervisor, e.Child, e.Err)
+}
+
+func (e *EscalationError) Unwrap() []error { return []error{ErrMaxRestarts, e.Err} }
+
+// PanicError is the error a child exits with when it panics.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("panic: %v", e.Value) }
+
+// Unwrap exposes the panic value when it was an error, e.g. a runtime.Error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type EventKind int
+
+const (
+	ChildExited     EventKind = iota // finished without error
+	ChildCrashed                     // returned an error or panicked
+	ChildRestarting                  // about to be started again
+	Escalated                        // restart intensity exceeded
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case ChildExited:
+		return "exited"
+	case ChildCrashed:
+		return "crashed"
+	case ChildRestarting:
+		return "restarting"
+	case Escalated:
+		return "escalated"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+type Event struct {
+	Supervisor string
+	Child      string
+	Kind       EventKind
+	Restarts   int // times this child has been restarted so far
+	Err        error
+	At         time.Time
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%s/%s %s (restarts=%d)", e.Supervisor, e.Child, e.Kind, e.Restarts)
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+type Supervisor struct {
+	name        string
+	strategy    Strategy
+	maxRestarts int
+	window      time.Duration
+	backoff     backoff.Strategy
+	clock       clock.Clock
+	children    []ChildSpec
+
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	health map[string]ServiceHealth
+	nested []*Supervisor
+}
+
+type Option func(*Supervisor)
+
+// WithIntensity allows at most n restarts within window before escalating.
+func WithIntensity(n int, window time.Duration) Option {
+	return func(s *Supervisor) {
+		s.maxRestarts = n
+		s.window = window
+	}
+}
+
+// WithBackoff delays restarts; the attempt is the number of recent restarts.
+func WithBackoff(b backoff.Strategy) Option { return func(s *Supervisor) { s.backoff = b } }
+
+func WithClock(c clock.Clock) Option { return func(s *Supervisor) { s.clock = c } }
+
+func NewSupervisor(name string, strategy Strategy, opts ...Option) *Supervisor {
+	s := &Supervisor{
+		name:        name,
+		strategy:    strategy,
+		maxRestarts: 3,
+		window:      5 * time.Second,
+		backoff:     backoff.Constant(0),
+		clock:       clock.Real{},
+		subs:        map[chan Event]struct{}{},
+		health:      map[string]ServiceHealth{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add registers a child. Children are launched in the order they were added
+// but run concurrently: Run does not wait for one to be up before launching
+// the next, so a child that needs a sibling must wait for it itself.
+func (s *Supervisor) Add(spec ChildSpec) { s.children = append(s.children, spec) }
+
+// Spec wraps the supervisor as a child of another supervisor.
+func (s *Supervisor) Spec(restart Restart) ChildSpec {
+	return ChildSpec{Name: s.name, Run: s.Run, Restart: restart}
+}
+
+// Subscribe returns a channel of events until ctx is canceled. Delivery is
+// best-effort: a subscriber whose buffer is full misses events.
+func (s *Supervisor) Subscribe(ctx context.Context, buf int) <-chan Event {
+	ch := make(chan Event, buf)
+
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subs, ch)
+		close(ch)
+		s.mu.Unlock()
+	}()
+
+	return ch
+}
+
+func (s *Supervisor) emit(ev Event) {
+	ev.Supervisor = s.name
+	ev.At = s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+type childExit struct {
+	idx int
+	gen int
+	err error
+}
+
+type runningChild struct {
+	gen    int
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Run starts every child and supervises them until ctx is canceled (returning
+// ctx's error), every child has finished for good (returning nil), or the
+// restart intensity is exceeded (returning an *EscalationError).
+func (s *Supervisor) Run(ctx context.Context) error {
+	exits := make(chan childExit)
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	running := make([]*runningChild, len(s.children))
+	gens := make([]int, len(s.children))
+	counts := make([]int, len(s.children)) // restarts per child
+	var restarts []time.Time
+	var delay time.Duration
+
+	start := func(i int, wait time.Duration) {
+		gens[i]++
+		cctx, cancel := context.WithCancel(ctx)
+		rc := &runningChild{gen: gens[i], cancel: cancel, done: make(chan struct{})}
+		running[i] = rc
+
+		go func(run func(context.Context) error) {
+			err := s.runChild(cctx, wait, run)
+			close(rc.done)
+			select {
+			case exits <- childExit{idx: i, gen: rc.gen, err: err}:
+			case <-stopped:
+			}
+		}(s.children[i].Run)
+	}
+	stop := func(i int) {
+		if rc := running[i]; rc != nil {
+			rc.cancel()
+			<-rc.done
+			running[i] = nil
+		}
+	}
+	stopAll := func() {
+		for i := len(running) - 1; i >= 0; i-- {
+			stop(i)
+		}
+	}
+
+	for i := range s.children {
+		start(i, 0)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			stopAll()
+			return ctx.Err()
+		case ev := <-exits:
+			rc := running[ev.idx]
+			if rc == nil || rc.gen != ev.gen {
+				continue // exit of a child we stopped ourselves
+			}
+			running[ev.idx] = nil
+			rc.cancel()
+
+			spec := s.children[ev.idx]
+			kind := ChildExited
+			if ev.err != nil {
+				kind = ChildCrashed
+			}
+			s.emit(Event{Child: spec.Name, Kind: kind, Restarts: counts[ev.idx], Err: ev.err})
+
+			if !shouldRestart(spec.Restart, ev.err) {
+				if allStopped(running) {
+					return nil
+				}
+				continue
+			}
+
+			now := s.clock.Now()
+			restarts = append(pruneBefore(restarts, now.Add(-s.window)), now)
+			if len(restarts) > s.maxRestarts {
+				stopAll()
+				s.emit(Event{Child: spec.Name, Kind: Escalated, Restarts: counts[ev.idx], Err: ev.err})
+				return &EscalationError{Supervisor: s.name, Child: spec.Name, Err: ev.err}
+			}
+			delay = s.backoff.Next(len(restarts)-1, delay)
+
+			// Stop every affected child before starting any, so none comes
+			// back up next to old instances of its siblings.
+			affected := s.affected(ev.idx, running)
+			for j := len(affected) - 1; j >= 0; j-- {
+				stop(affected[j])
+			}
+			for _, i := range affected {
+				if i != ev.idx && s.children[i].Restart == Temporary {
+					s.emit(Event{Child: s.children[i].Name, Kind: ChildExited, Restarts: counts[i]})
+					continue
+				}
+				counts[i]++
+				s.emit(Event{Child: s.children[i].Name, Kind: ChildRestarting, Restarts: counts[i], Err: ev.err})
+				start(i, delay)
+			}
+		}
+	}
+}
+
+// affected lists, in start order, the children to stop after idx exited.
+// Children that are not running stay down; Temporary ones are stopped but
+// not restarted.
+func (s *Supervisor) affected(idx int, running []*runningChild) []int {
+	var out []int
+	for i := range s.children {
+		switch {
+		case i == idx:
+			out = append(out, i)
+		case running[i] == nil:
+		case s.strategy == OneForAll, s.strategy == RestForOne && i > idx:
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (s *Supervisor) runChild(ctx context.Context, wait time.Duration, run func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	if wait > 0 {
+		t := s.clock.NewTimer(wait)
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+	return run(ctx)
+}
+
+func shouldRestart(r Restart, err error) bool {
+	switch r {
+	case Permanent:
+		return true
+	case Transient:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+func allStopped(running []*runningChild) bool {
+	for _, rc := range running {
+		if rc != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func pruneBefore(ts []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(cutoff) {
+		i++
+	}
+	return ts[i:]
+}
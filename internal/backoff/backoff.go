@@ -0,0 +1,112 @@
+// Package backoff computes retry and cool-down delays. Strategies range from a
+// constant delay to exponential growth with several flavours of jitter.
+//
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Strategy returns the delay before the given retry, counting from 0. prev is
+// the delay it returned for the previous retry (0 for the first), which
+// stateless strategies ignore.
+type Strategy interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// Constant waits the same amount every time.
+type Constant time.Duration
+
+func (c Constant) Next(int, time.Duration) time.Duration { return time.Duration(c) }
+
+// Exponential yields Base, Base*Multiplier, Base*Multiplier^2, ..., each plus
+// up to Jitter*delay of random extra time, and never more than Max.
+type Exponential struct {
+	Base       time.Duration
+	Max        time.Duration // 0 means no cap
+	Multiplier float64       // 0 means 2
+	Jitter     float64       // fraction of the delay, e.g. 0.2 for up to +20%
+}
+
+// Delay returns the delay before the given attempt, counting from 0.
+func (e Exponential) Delay(attempt int) time.Duration {
+	d := float64(capped(e.Base, e.Max, e.Multiplier, attempt))
+	if e.Jitter > 0 {
+		d += rand.Float64() * e.Jitter * d
+	}
+	if e.Max > 0 && d > float64(e.Max) {
+		d = float64(e.Max)
+	}
+	return time.Duration(d)
+}
+
+func (e Exponential) Next(attempt int, _ time.Duration) time.Duration { return e.Delay(attempt) }
+
+// FullJitter picks uniformly between 0 and the capped exponential delay.
+type FullJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (j FullJitter) Next(attempt int, _ time.Duration) time.Duration {
+	return randBetween(0, capped(j.Base, j.Max, 2, attempt))
+}
+
+// EqualJitter keeps half of the capped exponential delay and randomises the
+// other half.
+type EqualJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (j EqualJitter) Next(attempt int, _ time.Duration) time.Duration {
+	half := capped(j.Base, j.Max, 2, attempt) / 2
+	return half + randBetween(0, half)
+}
+
+// Decorrelated picks between Base and three times the previous delay, capped
+// at Max, so successive delays wander rather than strictly grow.
+type Decorrelated struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (j Decorrelated) Next(_ int, prev time.Duration) time.Duration {
+	if prev < j.Base {
+		prev = j.Base
+	}
+	d := randBetween(j.Base, 3*prev)
+	if j.Max > 0 && d > j.Max {
+		d = j.Max
+	}
+	return d
+}
+
+// capped returns base*mult^attempt, limited to max (if set) and to a value
+// that cannot overflow.
+func capped(base, max time.Duration, mult float64, attempt int) time.Duration {
+	if mult == 0 {
+		mult = 2
+	}
+	if attempt < 0 {
+		attempt = 0
+	}
+
+	d := float64(base) * math.Pow(mult, float64(attempt))
+	if max > 0 && d > float64(max) {
+		d = float64(max)
+	}
+	if d > math.MaxInt64/2 {
+		d = math.MaxInt64 / 2
+	}
+	return time.Duration(d)
+}
+
+func randBetween(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)))
+}